@@ -11,9 +11,11 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
@@ -36,6 +38,16 @@ type ECSTaskMeta struct {
 	Family           string                 `json:"Family"`
 	Containers       []ECSTaskMetaContainer `json:"Containers"`
 	AvailabilityZone string                 `json:"AvailabilityZone"`
+	Limits           ECSTaskMetaLimits      `json:"Limits"`
+}
+
+// ECSTaskMetaLimits holds the task level resource limits. The fields are nil
+// when the task metadata endpoint does not report them.
+type ECSTaskMetaLimits struct {
+	// CPU is the task CPU limit in vCPUs.
+	CPU *float64 `json:"CPU"`
+	// Memory is the task memory limit in MiB.
+	Memory *float64 `json:"Memory"`
 }
 
 type ECSTaskMetaContainer struct {
@@ -55,6 +67,7 @@ type ECSTaskMetaHealth struct {
 
 type ECSTaskMetaNetwork struct {
 	IPv4Addresses  []string `json:"IPv4Addresses"`
+	IPv6Addresses  []string `json:"IPv6Addresses"`
 	PrivateDNSName string   `json:"PrivateDNSName"`
 }
 
@@ -104,13 +117,37 @@ func (e ECSTaskMeta) Region() (string, error) {
 
 // NodeIP returns the IP of the node the task is running on.
 func (e ECSTaskMeta) NodeIP() string {
-	ip := "127.0.0.1" // default to localhost
-	if len(e.Containers) > 0 &&
-		len(e.Containers[0].Networks) > 0 &&
-		len(e.Containers[0].Networks[0].IPv4Addresses) > 0 {
-		ip = e.Containers[0].Networks[0].IPv4Addresses[0]
+	if ip := e.NodeIPv4(); ip != "" {
+		return ip
+	}
+	return "127.0.0.1" // default to localhost
+}
+
+// NodeIPv4 returns the IPv4 address of the task, or an empty string if the
+// task metadata does not report one.
+func (e ECSTaskMeta) NodeIPv4() string {
+	if network, ok := e.nodeNetwork(); ok && len(network.IPv4Addresses) > 0 {
+		return network.IPv4Addresses[0]
+	}
+	return ""
+}
+
+// NodeIPv6 returns the IPv6 address of the task, or an empty string if the
+// task metadata does not report one, such as for IPv4 only tasks.
+func (e ECSTaskMeta) NodeIPv6() string {
+	if network, ok := e.nodeNetwork(); ok && len(network.IPv6Addresses) > 0 {
+		return network.IPv6Addresses[0]
 	}
-	return ip
+	return ""
+}
+
+// nodeNetwork returns the first network of the first container. All containers
+// of a task share the task ENI in awsvpc network mode.
+func (e ECSTaskMeta) nodeNetwork() (ECSTaskMetaNetwork, bool) {
+	if len(e.Containers) > 0 && len(e.Containers[0].Networks) > 0 {
+		return e.Containers[0].Networks[0], true
+	}
+	return ECSTaskMetaNetwork{}, false
 }
 
 func (e ECSTaskMeta) HasContainerStopped(name string) bool {
@@ -154,6 +191,36 @@ func ECSTaskMetadata() (ECSTaskMeta, error) {
 	return metadataResp, nil
 }
 
+var taskMetaCache struct {
+	sync.Mutex
+	uri  string
+	meta ECSTaskMeta
+}
+
+// CachedECSTaskMetadata returns the task metadata, fetching it on the first
+// call and returning the same result on later calls, so callers in a process
+// share a single request to the metadata endpoint. Errors are not cached.
+// Callers that need the current container statuses, which change while the
+// task runs, must use ECSTaskMetadata instead.
+func CachedECSTaskMetadata() (ECSTaskMeta, error) {
+	taskMetaCache.Lock()
+	defer taskMetaCache.Unlock()
+
+	// The metadata is cached per endpoint, which only changes in tests.
+	metadataURI := os.Getenv(ECSMetadataURIEnvVar)
+	if metadataURI != "" && metadataURI == taskMetaCache.uri {
+		return taskMetaCache.meta, nil
+	}
+
+	meta, err := ECSTaskMetadata()
+	if err != nil {
+		return meta, err
+	}
+	taskMetaCache.uri = metadataURI
+	taskMetaCache.meta = meta
+	return meta, nil
+}
+
 func UserAgentHandler(caller string) request.NamedHandler {
 	return request.NamedHandler{
 		Name: "UserAgentHandler",
@@ -169,6 +236,8 @@ func UserAgentHandler(caller string) request.NamedHandler {
 // The returned session includes a User-Agent handler to enable AWS to track usage.
 // If the AWS SDK fails to find the region, the region is parsed from Task metadata
 // (on EC2 the region is not typically defined in the environment).
+// The session relies on the SDK's default HTTP client, which honors the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
 func NewSession(meta ECSTaskMeta, userAgentCaller string) (*session.Session, error) {
 	clientSession, err := session.NewSession()
 	if err != nil {
@@ -188,6 +257,30 @@ func NewSession(meta ECSTaskMeta, userAgentCaller string) (*session.Session, err
 	return clientSession, nil
 }
 
-func GetAWSRegion() string {
-	return os.Getenv(AWSRegionEnvVar)
+// GetAWSRegion returns the AWS region of the task. It uses the AWS_REGION
+// environment variable, falling back to the region of the task ARN and then
+// to the region of the EC2 instance from the instance metadata service (IMDS).
+// It returns an empty string if the region cannot be determined.
+func GetAWSRegion(meta ECSTaskMeta) string {
+	if region := os.Getenv(AWSRegionEnvVar); region != "" {
+		return region
+	}
+	if region, err := meta.Region(); err == nil && region != "" {
+		return region
+	}
+	return imdsRegion()
+}
+
+// imdsRegion returns the region of the EC2 instance from IMDS, or an empty
+// string if IMDS is not available, such as on Fargate.
+func imdsRegion() string {
+	clientSession, err := session.NewSession()
+	if err != nil {
+		return ""
+	}
+	region, err := ec2metadata.New(clientSession).Region()
+	if err != nil {
+		return ""
+	}
+	return region
 }
@@ -5,8 +5,11 @@ package awsutil
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws/request"
@@ -107,6 +110,40 @@ func TestECSTaskMeta(t *testing.T) {
 	require.Equal(t, clusterArn, "arn:aws:ecs:us-east-1:123456789:cluster/test")
 }
 
+func TestCachedECSTaskMetadata(t *testing.T) {
+	var requests, failures int32
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/task", r.URL.Path)
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&failures) > 0 {
+			atomic.AddInt32(&failures, -1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"Cluster": "test", "TaskARN": "arn:aws:ecs:us-east-1:123456789:task/test/abcdef"}`)
+	}))
+	t.Cleanup(metadataServer.Close)
+
+	// Errors are not cached, so a later call retries the request.
+	failures = 1
+	t.Setenv(ECSMetadataURIEnvVar, metadataServer.URL)
+	_, err := CachedECSTaskMetadata()
+	require.Error(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	for i := 0; i < 3; i++ {
+		meta, err := CachedECSTaskMetadata()
+		require.NoError(t, err)
+		require.Equal(t, "abcdef", meta.TaskID())
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// The uncached accessor always calls the endpoint.
+	_, err = ECSTaskMetadata()
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
 func TestHasContainerStopped(t *testing.T) {
 	taskMeta := ECSTaskMeta{}
 	taskMeta.Containers = []ECSTaskMetaContainer{
@@ -209,10 +246,87 @@ func TestECSTaskMeta_NodeIP(t *testing.T) {
 	}
 }
 
+func TestECSTaskMeta_NodeIPv4AndIPv6(t *testing.T) {
+	cases := map[string]struct {
+		ecsMeta     ECSTaskMeta
+		expNodeIPv4 string
+		expNodeIPv6 string
+	}{
+		"no containers": {
+			ecsMeta: ECSTaskMeta{},
+		},
+		"ipv4 only": {
+			ecsMeta: ECSTaskMeta{
+				Containers: []ECSTaskMetaContainer{{
+					Networks: []ECSTaskMetaNetwork{{
+						IPv4Addresses: []string{"10.1.2.3"},
+					}},
+				}},
+			},
+			expNodeIPv4: "10.1.2.3",
+		},
+		"dual-stack": {
+			ecsMeta: ECSTaskMeta{
+				Containers: []ECSTaskMetaContainer{{
+					Networks: []ECSTaskMetaNetwork{{
+						IPv4Addresses: []string{"10.1.2.3"},
+						IPv6Addresses: []string{"2001:db8::1"},
+					}},
+				}},
+			},
+			expNodeIPv4: "10.1.2.3",
+			expNodeIPv6: "2001:db8::1",
+		},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expNodeIPv4, c.ecsMeta.NodeIPv4())
+		require.Equal(t, c.expNodeIPv6, c.ecsMeta.NodeIPv6())
+	}
+}
+
 func TestGetAWSRegion(t *testing.T) {
-	t.Setenv(AWSRegionEnvVar, "")
-	require.Empty(t, GetAWSRegion())
+	taskMeta := ECSTaskMeta{TaskARN: "arn:aws:ecs:us-east-1:123456789:task/test/abcdef"}
+
+	imdsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			fmt.Fprint(w, "token")
+		case "/latest/dynamic/instance-identity/document":
+			fmt.Fprint(w, `{"region": "eu-west-1"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(imdsServer.Close)
 
-	t.Setenv(AWSRegionEnvVar, "us-west-2")
-	require.Equal(t, "us-west-2", GetAWSRegion())
+	cases := map[string]struct {
+		region       string
+		taskMeta     ECSTaskMeta
+		imdsDisabled bool
+		expRegion    string
+	}{
+		"env var": {
+			region:    "us-west-2",
+			taskMeta:  taskMeta,
+			expRegion: "us-west-2",
+		},
+		"task ARN fallback": {
+			taskMeta:  taskMeta,
+			expRegion: "us-east-1",
+		},
+		"IMDS fallback": {
+			expRegion: "eu-west-1",
+		},
+		"no region": {
+			imdsDisabled: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(AWSRegionEnvVar, c.region)
+			t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", imdsServer.URL)
+			t.Setenv("AWS_EC2_METADATA_DISABLED", fmt.Sprint(c.imdsDisabled))
+			require.Equal(t, c.expRegion, GetAWSRegion(c.taskMeta))
+		})
+	}
 }
@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package awsutil
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
+)
+
+const (
+	// cloudMapIPv4Attribute and cloudMapIPv6Attribute are the instance
+	// attributes that ECS sets to the task IPs when it registers a task
+	// with a Cloud Map service.
+	cloudMapIPv4Attribute = "AWS_INSTANCE_IPV4"
+	cloudMapIPv6Attribute = "AWS_INSTANCE_IPV6"
+)
+
+// CloudMapInstanceIPs returns the IPs of the instances registered with the
+// Cloud Map service in the namespace, sorted. Healthy instances are returned
+// when there are any, otherwise all instances are returned, so that servers
+// can still be reached while their Cloud Map health checks are failing.
+func CloudMapInstanceIPs(client servicediscoveryiface.ServiceDiscoveryAPI, namespace, service string) ([]string, error) {
+	resp, err := client.DiscoverInstances(&servicediscovery.DiscoverInstancesInput{
+		NamespaceName: aws.String(namespace),
+		ServiceName:   aws.String(service),
+		HealthStatus:  aws.String(servicediscovery.HealthStatusFilterHealthyOrElseAll),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("discovering instances of Cloud Map service %s in namespace %s: %w", service, namespace, err)
+	}
+
+	var ips []string
+	for _, instance := range resp.Instances {
+		ip := aws.StringValue(instance.Attributes[cloudMapIPv4Attribute])
+		if ip == "" {
+			ip = aws.StringValue(instance.Attributes[cloudMapIPv6Attribute])
+		}
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no instances with an IP address found for Cloud Map service %s in namespace %s", service, namespace)
+	}
+	sort.Strings(ips)
+	return ips, nil
+}
@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package awsutil
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServiceDiscovery struct {
+	servicediscoveryiface.ServiceDiscoveryAPI
+
+	input     *servicediscovery.DiscoverInstancesInput
+	instances []*servicediscovery.HttpInstanceSummary
+	err       error
+}
+
+func (f *fakeServiceDiscovery) DiscoverInstances(input *servicediscovery.DiscoverInstancesInput) (*servicediscovery.DiscoverInstancesOutput, error) {
+	f.input = input
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &servicediscovery.DiscoverInstancesOutput{Instances: f.instances}, nil
+}
+
+func instance(attributes map[string]string) *servicediscovery.HttpInstanceSummary {
+	return &servicediscovery.HttpInstanceSummary{Attributes: aws.StringMap(attributes)}
+}
+
+func TestCloudMapInstanceIPs(t *testing.T) {
+	cases := map[string]struct {
+		instances   []*servicediscovery.HttpInstanceSummary
+		err         error
+		expectedIPs []string
+		expectedErr string
+	}{
+		"IPv4 instances are sorted": {
+			instances: []*servicediscovery.HttpInstanceSummary{
+				instance(map[string]string{"AWS_INSTANCE_IPV4": "10.0.0.2"}),
+				instance(map[string]string{"AWS_INSTANCE_IPV4": "10.0.0.1"}),
+			},
+			expectedIPs: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		"IPv6 is used without an IPv4 address": {
+			instances: []*servicediscovery.HttpInstanceSummary{
+				instance(map[string]string{"AWS_INSTANCE_IPV4": "10.0.0.1", "AWS_INSTANCE_IPV6": "2001:db8::1"}),
+				instance(map[string]string{"AWS_INSTANCE_IPV6": "2001:db8::2"}),
+			},
+			expectedIPs: []string{"10.0.0.1", "2001:db8::2"},
+		},
+		"instances without an IP are skipped": {
+			instances: []*servicediscovery.HttpInstanceSummary{
+				instance(map[string]string{"AWS_INIT_HEALTH_STATUS": "HEALTHY"}),
+				instance(map[string]string{"AWS_INSTANCE_IPV4": "10.0.0.1"}),
+			},
+			expectedIPs: []string{"10.0.0.1"},
+		},
+		"no instances": {
+			expectedErr: "no instances with an IP address found for Cloud Map service consul-server in namespace consul.local",
+		},
+		"discover error": {
+			err:         fmt.Errorf("access denied"),
+			expectedErr: "discovering instances of Cloud Map service consul-server in namespace consul.local: access denied",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := &fakeServiceDiscovery{instances: c.instances, err: c.err}
+
+			ips, err := CloudMapInstanceIPs(client, "consul.local", "consul-server")
+			require.Equal(t, "consul.local", aws.StringValue(client.input.NamespaceName))
+			require.Equal(t, "consul-server", aws.StringValue(client.input.ServiceName))
+			require.Equal(t, servicediscovery.HealthStatusFilterHealthyOrElseAll, aws.StringValue(client.input.HealthStatus))
+			if c.expectedErr != "" {
+				require.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expectedIPs, ips)
+		})
+	}
+}
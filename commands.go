@@ -6,10 +6,15 @@ package main
 import (
 	"os"
 
+	"github.com/hashicorp/consul-ecs/config"
 	cmdAppEntrypoint "github.com/hashicorp/consul-ecs/subcommand/app-entrypoint"
+	cmdCloudMapAddresses "github.com/hashicorp/consul-ecs/subcommand/cloud-map-addresses"
+	cmdConfigShow "github.com/hashicorp/consul-ecs/subcommand/config-show"
+	cmdConfigValidate "github.com/hashicorp/consul-ecs/subcommand/config-validate"
 	cmdController "github.com/hashicorp/consul-ecs/subcommand/controller"
 	cmdEnvoyEntrypoint "github.com/hashicorp/consul-ecs/subcommand/envoy-entrypoint"
 	cmdHealthSync "github.com/hashicorp/consul-ecs/subcommand/health-sync"
+	cmdMeshDeregister "github.com/hashicorp/consul-ecs/subcommand/mesh-deregister"
 	cmdMeshInit "github.com/hashicorp/consul-ecs/subcommand/mesh-init"
 	cmdNetDial "github.com/hashicorp/consul-ecs/subcommand/net-dial"
 	cmdVersion "github.com/hashicorp/consul-ecs/subcommand/version"
@@ -45,6 +50,18 @@ func init() {
 		"health-sync": func() (cli.Command, error) {
 			return &cmdHealthSync.Command{UI: ui}, nil
 		},
+		"config validate": func() (cli.Command, error) {
+			return &cmdConfigValidate.Command{UI: ui}, nil
+		},
+		"config show": func() (cli.Command, error) {
+			return &cmdConfigShow.Command{UI: ui}, nil
+		},
+		"mesh deregister": func() (cli.Command, error) {
+			return &cmdMeshDeregister.Command{UI: ui}, nil
+		},
+		config.CloudMapAddressesCommand: func() (cli.Command, error) {
+			return &cmdCloudMapAddresses.Command{UI: ui}, nil
+		},
 	}
 }
 
@@ -53,7 +70,10 @@ func helpFunc() cli.HelpFunc {
 	// Hidden commands can still be executed if you know the command, but
 	// aren't shown in any help output. We use this for prerelease functionality
 	// or advanced features.
-	hidden := map[string]struct{}{}
+	hidden := map[string]struct{}{
+		// Only run by consul-ecs itself, to discover the Consul servers.
+		config.CloudMapAddressesCommand: {},
+	}
 
 	var include []string
 	for k := range Commands {
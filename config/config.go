@@ -5,34 +5,63 @@ package config
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
 	iamauth "github.com/hashicorp/consul-awsauth"
 	"github.com/hashicorp/consul-ecs/awsutil"
 	"github.com/hashicorp/consul-server-connection-manager/discovery"
 	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-rootcerts"
+	"golang.org/x/net/http/httpproxy"
 )
 
 const (
 	// Cert used for internal RPC communication to the servers
 	ConsulGRPCCACertPemEnvVar = "CONSUL_GRPC_CACERT_PEM"
 
+	// CloudMapAddressesCommand is the consul-ecs command that prints the Consul
+	// server IPs registered with Cloud Map, for go-netaddrs.
+	CloudMapAddressesCommand = "cloud-map-addresses"
+
 	// Login meta fields added to the token
-	ConsulTokenTaskIDMeta    = "consul.hashicorp.com/task-id"
-	ConsulTokenClusterIDMeta = "consul.hashicorp.com/cluster"
+	ConsulTokenTaskIDMeta     = "consul.hashicorp.com/task-id"
+	ConsulTokenClusterIDMeta  = "consul.hashicorp.com/cluster"
+	ConsulTokenTaskFamilyMeta = "consul.hashicorp.com/task-family"
 
 	defaultGRPCPort    = 8503
 	defaultHTTPPort    = 8501
+	defaultHTTPTimeout = 30 * time.Second
 	defaultIAMRolePath = "/consul-ecs/"
 
+	// The ECS task metadata endpoint is rate limited to about 40 requests per
+	// second, so health-sync should not poll it more than once per second.
+	defaultHealthSyncInterval = 10 * time.Second
+	minHealthSyncInterval     = 1 * time.Second
+
+	defaultBootstrapFileMode os.FileMode = 0444
+
+	// defaultBinaryName is the file in the BootstrapDir that the consul-ecs binary is copied to.
+	defaultBinaryName = "consul-ecs"
+
+	// Consul reserves node meta keys with this prefix for its own use.
+	reservedNodeMetaPrefix = "consul-"
+
 	// Cert used for securing HTTP traffic towards the server
 	consulHTTPSCertPemEnvVar = "CONSUL_HTTPS_CACERT_PEM"
 
@@ -46,8 +75,13 @@ type TLSSettings struct {
 }
 
 func (c *Config) ConsulServerConnMgrConfig(taskMeta awsutil.ECSTaskMeta) (discovery.Config, error) {
+	addresses, err := c.ConsulServers.addresses()
+	if err != nil {
+		return discovery.Config{}, err
+	}
+
 	cfg := discovery.Config{
-		Addresses: c.ConsulServers.Hosts,
+		Addresses: addresses,
 		GRPCPort:  c.ConsulServers.GRPC.Port,
 	}
 
@@ -55,10 +89,15 @@ func (c *Config) ConsulServerConnMgrConfig(taskMeta awsutil.ECSTaskMeta) (discov
 	if grpcTLSSettings.Enabled {
 		tlsConfig := &tls.Config{}
 
-		caCert := os.Getenv(ConsulGRPCCACertPemEnvVar)
-		if caCert != "" {
+		// Skipped PEM blocks are logged by mesh-init when it writes the bundle
+		// for consul-dataplane.
+		caCert, err := GetGRPCCACertBundle(hclog.NewNullLogger())
+		if err != nil {
+			return discovery.Config{}, err
+		}
+		if caCert != nil {
 			err := rootcerts.ConfigureTLS(tlsConfig, &rootcerts.Config{
-				CACertificate: []byte(caCert),
+				CACertificate: caCert,
 			})
 			if err != nil {
 				return discovery.Config{}, err
@@ -103,9 +142,10 @@ func (c *Config) ConsulServerConnMgrConfig(taskMeta awsutil.ECSTaskMeta) (discov
 
 func (c *Config) ClientConfig() *api.Config {
 	cfg := &api.Config{
-		Namespace: c.getNamespace(),
-		Partition: c.getPartition(),
-		Scheme:    "http",
+		Namespace:  c.getNamespace(),
+		Partition:  c.getPartition(),
+		Datacenter: c.ConsulServers.Datacenter,
+		Scheme:     "http",
 	}
 
 	httpTLSSettings := c.ConsulServers.getHTTPTLSSettings()
@@ -116,6 +156,8 @@ func (c *Config) ClientConfig() *api.Config {
 		caCert := os.Getenv(consulHTTPSCertPemEnvVar)
 		if caCert != "" {
 			cfg.TLSConfig.CAPem = []byte(caCert)
+		} else if c.ConsulServers.HTTP.CaCertPem != "" {
+			cfg.TLSConfig.CAPem = []byte(c.ConsulServers.HTTP.CaCertPem)
 		} else if httpTLSSettings.CaCertFile != "" {
 			cfg.TLSConfig.CAFile = httpTLSSettings.CaCertFile
 		}
@@ -130,10 +172,142 @@ func (c *Config) ClientConfig() *api.Config {
 	return cfg
 }
 
+// NewConsulAPIClient returns a Consul API client for the given client config.
+// Every request made by the client is bounded by `consulServers.httpTimeout`
+// so that a hung Consul server cannot block the caller indefinitely.
+// Requests are routed through the proxy given by HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY, if set.
+func (c *Config) NewConsulAPIClient(cfg *api.Config) (*api.Client, error) {
+	return c.newConsulAPIClient(cfg, c.ConsulServers.GetHTTPTimeout())
+}
+
+// NewBlockingConsulAPIClient returns a Consul API client for the given client
+// config whose requests are not bounded by `consulServers.httpTimeout`. It is
+// meant for long-lived blocking queries, such as those of a Consul lock, which
+// wait on the server for longer than the HTTP timeout.
+func (c *Config) NewBlockingConsulAPIClient(cfg *api.Config) (*api.Client, error) {
+	return c.newConsulAPIClient(cfg, 0)
+}
+
+func (c *Config) newConsulAPIClient(cfg *api.Config, timeout time.Duration) (*api.Client, error) {
+	if cfg.Transport == nil {
+		cfg.Transport = cleanhttp.DefaultPooledTransport()
+		cfg.Transport.Proxy = proxyFromEnvironment
+	}
+
+	httpClient, err := api.NewHttpClient(cfg.Transport, cfg.TLSConfig)
+	if err != nil {
+		return nil, fmt.Errorf("constructing consul http client: %w", err)
+	}
+	httpClient.Timeout = timeout
+	cfg.HttpClient = httpClient
+
+	return api.NewClient(cfg)
+}
+
+// proxyFromEnvironment returns the proxy URL for the request based on the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. Unlike
+// http.ProxyFromEnvironment, the environment is read on every call rather
+// than cached for the lifetime of the process.
+func proxyFromEnvironment(req *http.Request) (*url.URL, error) {
+	return httpproxy.FromEnvironment().ProxyFunc()(req.URL)
+}
+
 func (c *Config) IsGateway() bool {
 	return c.Gateway != nil && c.Gateway.Kind != ""
 }
 
+// SidecarEnabled returns whether a sidecar proxy is registered for the
+// service. Connect-native services never have a sidecar proxy.
+func (c *Config) SidecarEnabled() bool {
+	return c.Mesh.GetSidecarEnabled() && !c.Service.ConnectNative
+}
+
+// GetHealthSyncContainers returns the names of the containers whose ECS health
+// is synced to Consul checks. Entries in healthSyncContainers that contain a
+// wildcard are glob patterns, such as `app-*`, that are expanded to the matching
+// containers in the task. The consul-dataplane container has its own readiness
+// check, so it is never matched by a pattern. Literal names are returned as is,
+// so that a container missing from the task is reported as critical. The
+// healthSyncNonEssentialContainers are returned after them.
+func (c *Config) GetHealthSyncContainers(taskMeta awsutil.ECSTaskMeta) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, entry := range c.HealthSyncContainers {
+		if !isHealthSyncPattern(entry) {
+			add(entry)
+			continue
+		}
+		for _, container := range taskMeta.Containers {
+			if container.Name == ConsulDataplaneContainerName {
+				continue
+			}
+			// The pattern is validated when the config is parsed.
+			if ok, _ := path.Match(entry, container.Name); ok {
+				add(container.Name)
+			}
+		}
+	}
+	for _, name := range c.HealthSyncNonEssentialContainers {
+		add(name)
+	}
+	return names
+}
+
+// IsNonEssentialHealthSyncContainer returns true if the container is one of
+// the healthSyncNonEssentialContainers.
+func (c *Config) IsNonEssentialHealthSyncContainer(name string) bool {
+	for _, n := range c.HealthSyncNonEssentialContainers {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isHealthSyncPattern returns true if the healthSyncContainers entry is a glob pattern.
+func isHealthSyncPattern(entry string) bool {
+	return strings.ContainsAny(entry, `*?[\`)
+}
+
+// addresses returns the go-netaddrs config for the Consul server addresses.
+// With consulServers.cloudMap, the consul-ecs binary is run to look up the
+// Cloud Map instances, so that the addresses are refreshed whenever the
+// servers are rediscovered.
+func (c *ConsulServers) addresses() (string, error) {
+	if c.CloudMap == nil {
+		return c.Hosts, nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("finding the consul-ecs binary for Cloud Map discovery: %w", err)
+	}
+	return c.CloudMapExecAddresses(executable), nil
+}
+
+// CloudMapExecAddresses returns the go-netaddrs exec address that runs the
+// consul-ecs binary at executable to look up the consulServers.cloudMap
+// instances.
+func (c *ConsulServers) CloudMapExecAddresses(executable string) string {
+	return fmt.Sprintf("exec=%s %s -namespace %s -service %s",
+		executable, CloudMapAddressesCommand, c.CloudMap.Namespace, c.CloudMap.Service)
+}
+
+// GetHTTPTimeout returns the timeout for requests to the Consul HTTP API.
+func (c *ConsulServers) GetHTTPTimeout() time.Duration {
+	if c.HTTPTimeout > 0 {
+		return time.Duration(c.HTTPTimeout)
+	}
+	return defaultHTTPTimeout
+}
+
 func (c *ConsulServers) GetGRPCTLSSettings() *TLSSettings {
 	enableTLS := c.Defaults.EnableTLS
 	if c.GRPC.EnableTLS != nil {
@@ -157,6 +331,66 @@ func (c *ConsulServers) GetGRPCTLSSettings() *TLSSettings {
 	}
 }
 
+// LoadGRPCCACertSecret fetches the gRPC CA cert from the Secrets Manager secret
+// in consulServers.grpc.caCertSecretArn and sets CONSUL_GRPC_CACERT_PEM to it,
+// so that it is used the same way as a CA cert injected into the environment.
+// It is a no-op if no secret is configured or CONSUL_GRPC_CACERT_PEM is set.
+func (c *Config) LoadGRPCCACertSecret(smClient secretsmanageriface.SecretsManagerAPI) error {
+	secretARN := c.ConsulServers.GRPC.CaCertSecretARN
+	if secretARN == "" || os.Getenv(ConsulGRPCCACertPemEnvVar) != "" {
+		return nil
+	}
+
+	secret, err := smClient.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretARN),
+	})
+	if err != nil {
+		return fmt.Errorf("fetching the gRPC CA cert from secret %s: %w", secretARN, err)
+	}
+	pem := aws.StringValue(secret.SecretString)
+	if !x509.NewCertPool().AppendCertsFromPEM([]byte(pem)) {
+		return fmt.Errorf("secret %s must contain a PEM encoded certificate", secretARN)
+	}
+	return os.Setenv(ConsulGRPCCACertPemEnvVar, pem)
+}
+
+// GetGRPCCACertBundle returns the certificates in CONSUL_GRPC_CACERT_PEM as a
+// single PEM bundle, or nil if it is unset. This allows passing both the root
+// and a rotating intermediate CA, for example. The PEM blocks may be separated
+// by newlines or commas. Blocks that do not parse as certificates are logged
+// and left out of the bundle.
+func GetGRPCCACertBundle(log hclog.Logger) ([]byte, error) {
+	pemData := os.Getenv(ConsulGRPCCACertPemEnvVar)
+	if pemData == "" {
+		return nil, nil
+	}
+
+	var bundle []byte
+	// PEM blocks must start on a new line. Commas are not valid within certificate blocks.
+	rest := []byte(strings.ReplaceAll(pemData, ",", "\n"))
+	for i := 0; ; i++ {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			log.Warn("skipping PEM block that is not a certificate", "env", ConsulGRPCCACertPemEnvVar, "index", i, "type", block.Type)
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			log.Warn("skipping invalid certificate", "env", ConsulGRPCCACertPemEnvVar, "index", i, "error", err)
+			continue
+		}
+		bundle = append(bundle, pem.EncodeToMemory(block)...)
+	}
+
+	if len(bundle) == 0 {
+		return nil, fmt.Errorf("no valid certificates found in %s", ConsulGRPCCACertPemEnvVar)
+	}
+	return bundle, nil
+}
+
 func GetConsulToken() string {
 	return os.Getenv(bootstrapTokenEnvVar)
 }
@@ -169,6 +403,9 @@ func (c *Config) getLoginDiscoveryCredentials(taskMeta awsutil.ECSTaskMeta) (dis
 			Partition:  c.getPartition(),
 		},
 	}
+	if cfg.Login.Datacenter == "" {
+		cfg.Login.Datacenter = c.ConsulServers.Datacenter
+	}
 
 	authMethod := c.ConsulLogin.Method
 	if authMethod == "" {
@@ -183,8 +420,9 @@ func (c *Config) getLoginDiscoveryCredentials(taskMeta awsutil.ECSTaskMeta) (dis
 
 	cfg.Login.Meta = mergeMeta(
 		map[string]string{
-			ConsulTokenTaskIDMeta:    taskMeta.TaskID(),
-			ConsulTokenClusterIDMeta: clusterARN,
+			ConsulTokenTaskIDMeta:     taskMeta.TaskID(),
+			ConsulTokenClusterIDMeta:  clusterARN,
+			ConsulTokenTaskFamilyMeta: taskMeta.Family,
 		},
 		c.ConsulLogin.Meta,
 	)
@@ -4,11 +4,24 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/hashicorp/consul-ecs/awsutil"
+	"github.com/hashicorp/consul-ecs/controller/mocks"
 	"github.com/hashicorp/consul-ecs/testutil"
 	"github.com/hashicorp/consul-server-connection-manager/discovery"
 	"github.com/hashicorp/consul/api"
@@ -204,10 +217,47 @@ func TestConsulServerConnManagerConfig(t *testing.T) {
 							Datacenter: "test-dc",
 							Partition:  "test-partition",
 							Meta: map[string]string{
-								"key1":                         "value1",
-								"key2":                         "value2",
-								"consul.hashicorp.com/task-id": e.TaskID(),
-								"consul.hashicorp.com/cluster": clusterARN,
+								"key1":                             "value1",
+								"key2":                             "value2",
+								"consul.hashicorp.com/task-id":     e.TaskID(),
+								"consul.hashicorp.com/cluster":     clusterARN,
+								"consul.hashicorp.com/task-family": e.Family,
+							},
+						},
+					},
+				}
+			},
+		},
+		"ACL Auth method defaults to the server datacenter": {
+			cfg: &Config{
+				ConsulServers: ConsulServers{
+					Hosts:      "consul.dc1.address",
+					Datacenter: "dc1",
+				},
+				ConsulLogin: ConsulLogin{
+					Enabled: true,
+					Method:  "test-auth-method",
+				},
+			},
+			taskMeta: awsutil.ECSTaskMeta{
+				Cluster: "test-cluster",
+				TaskARN: "arn:aws:ecs:us-east-1:123456789:task/test/abcdef",
+				Family:  "family-service",
+			},
+			expConfig: func(t *testing.T, e awsutil.ECSTaskMeta) discovery.Config {
+				clusterARN, err := e.ClusterARN()
+				require.NoError(t, err)
+				return discovery.Config{
+					Addresses: "consul.dc1.address",
+					Credentials: discovery.Credentials{
+						Type: discovery.CredentialsTypeLogin,
+						Login: discovery.LoginCredential{
+							AuthMethod: "test-auth-method",
+							Datacenter: "dc1",
+							Meta: map[string]string{
+								"consul.hashicorp.com/task-id":     e.TaskID(),
+								"consul.hashicorp.com/cluster":     clusterARN,
+								"consul.hashicorp.com/task-family": e.Family,
 							},
 						},
 					},
@@ -347,6 +397,126 @@ func TestConsulServerConnManagerConfig_TLS(t *testing.T) {
 	}
 }
 
+func TestConsulServerConnManagerConfig_CACertBundle(t *testing.T) {
+	_, caPEM1, _ := generateCA(t, "ca-1")
+	caCert2, caPEM2, caKey2 := generateCA(t, "ca-2")
+
+	// The server cert is signed by the second CA in the bundle.
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serverTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server.dc1.consul"},
+		DNSNames:     []string{"server.dc1.consul"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, serverTemplate, caCert2, &serverKey.PublicKey, caKey2)
+	require.NoError(t, err)
+	serverCert, err := x509.ParseCertificate(serverDER)
+	require.NoError(t, err)
+
+	cfg := &Config{
+		ConsulServers: ConsulServers{
+			Hosts: "consul.dc1.address",
+			GRPC: GRPCSettings{
+				Port:      8503,
+				EnableTLS: testutil.BoolPtr(true),
+			},
+		},
+	}
+
+	for name, sep := range map[string]string{"newline": "\n", "comma": ","} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(ConsulGRPCCACertPemEnvVar, caPEM1+sep+caPEM2)
+
+			connMgrCfg, err := cfg.ConsulServerConnMgrConfig(awsutil.ECSTaskMeta{})
+			require.NoError(t, err)
+			_, err = serverCert.Verify(x509.VerifyOptions{
+				DNSName: "server.dc1.consul",
+				Roots:   connMgrCfg.TLS.RootCAs,
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+// generateCA returns a self-signed CA certificate, its PEM encoding and its key.
+func generateCA(t *testing.T, commonName string) (*x509.Certificate, string, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), key
+}
+
+func TestLoadGRPCCACertSecret(t *testing.T) {
+	const secretARN = "arn:aws:secretsmanager:us-east-1:123456789:secret:consul-ca-cert"
+	cases := map[string]struct {
+		secretARN string
+		secret    string
+		envPEM    string
+		expPEM    string
+		expError  string
+	}{
+		"no secret": {
+			secret: testCA,
+		},
+		"secret with a certificate": {
+			secretARN: secretARN,
+			secret:    testCA,
+			expPEM:    testCA,
+		},
+		"secret without a certificate": {
+			secretARN: secretARN,
+			secret:    "not a certificate",
+			expError:  "secret " + secretARN + " must contain a PEM encoded certificate",
+		},
+		"env var takes precedence": {
+			secretARN: secretARN,
+			secret:    testCA,
+			envPEM:    "env-pem",
+			expPEM:    "env-pem",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(ConsulGRPCCACertPemEnvVar, c.envPEM)
+
+			cfg := &Config{
+				ConsulServers: ConsulServers{
+					GRPC: GRPCSettings{CaCertSecretARN: c.secretARN},
+				},
+			}
+			smClient := &mocks.SMClient{
+				Secret: &secretsmanager.GetSecretValueOutput{SecretString: aws.String(c.secret)},
+			}
+			err := cfg.LoadGRPCCACertSecret(smClient)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expPEM, os.Getenv(ConsulGRPCCACertPemEnvVar))
+		})
+	}
+}
+
 func TestClientConfig(t *testing.T) {
 	caFile := writeCAFile(t)
 	cases := map[string]struct {
@@ -387,6 +557,21 @@ func TestClientConfig(t *testing.T) {
 				Partition: "test-par",
 			},
 		},
+		"basic flags without TLS and custom datacenter": {
+			cfg: &Config{
+				ConsulServers: ConsulServers{
+					Hosts:      "consul.dc1.address",
+					Datacenter: "dc2",
+					Defaults: DefaultSettings{
+						EnableTLS: false,
+					},
+				},
+			},
+			expConfig: &api.Config{
+				Scheme:     "http",
+				Datacenter: "dc2",
+			},
+		},
 		"basic flags without TLS and custom gateway namespace and partition": {
 			cfg: &Config{
 				ConsulServers: ConsulServers{
@@ -499,6 +684,28 @@ func TestClientConfig(t *testing.T) {
 				},
 			},
 		},
+		"TLS with CaCertPem from config": {
+			cfg: &Config{
+				ConsulServers: ConsulServers{
+					Hosts: "consul.dc1.address",
+					HTTP: HTTPSettings{
+						Port:        8501,
+						EnableHTTPS: true,
+						CaCertPem:   testCA,
+					},
+					Defaults: DefaultSettings{
+						CaCertFile: caFile.Name(),
+					},
+				},
+			},
+			expConfig: &api.Config{
+				Scheme: "https",
+				TLSConfig: api.TLSConfig{
+					Address: "consul.dc1.address",
+					CAPem:   []byte(testCA),
+				},
+			},
+		},
 	}
 
 	for name, c := range cases {
@@ -513,6 +720,115 @@ func TestClientConfig(t *testing.T) {
 	}
 }
 
+func TestNewConsulAPIClientTimeout(t *testing.T) {
+	// The server never responds, so requests only return once the timeout fires.
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(unblock) })
+
+	timeout := 500 * time.Millisecond
+	cfg := &Config{ConsulServers: ConsulServers{HTTPTimeout: Duration(timeout)}}
+	require.Equal(t, defaultHTTPTimeout, (&ConsulServers{}).GetHTTPTimeout())
+	require.Equal(t, timeout, cfg.ConsulServers.GetHTTPTimeout())
+
+	client, err := cfg.NewConsulAPIClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+
+	requests := map[string]func() error{
+		"catalog register": func() error {
+			_, err := client.Catalog().Register(&api.CatalogRegistration{Node: "test-node"}, nil)
+			return err
+		},
+		"acl token read": func() error {
+			_, _, err := client.ACL().TokenReadSelf(nil)
+			return err
+		},
+	}
+	for name, request := range requests {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			err := request()
+			require.Error(t, err)
+			require.Less(t, time.Since(start), 5*timeout)
+		})
+	}
+}
+
+func TestNewBlockingConsulAPIClient(t *testing.T) {
+	timeout := 100 * time.Millisecond
+	// The server holds the request for longer than the HTTP timeout, like a
+	// blocking query of a Consul lock.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(3 * timeout)
+		w.Header().Set("X-Consul-Index", "1")
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &Config{ConsulServers: ConsulServers{HTTPTimeout: Duration(timeout)}}
+
+	client, err := cfg.NewConsulAPIClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+	_, _, err = client.KV().List("consul-ecs/controller-leader/", &api.QueryOptions{WaitIndex: 1})
+	require.Error(t, err)
+
+	client, err = cfg.NewBlockingConsulAPIClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+	_, _, err = client.KV().List("consul-ecs/controller-leader/", &api.QueryOptions{WaitIndex: 1})
+	require.NoError(t, err)
+}
+
+func TestNewConsulAPIClientProxy(t *testing.T) {
+	var proxiedHosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Proxied requests carry the absolute URL of the target.
+		proxiedHosts = append(proxiedHosts, r.URL.Host)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`"10.0.0.1:8300"`))
+	}))
+	t.Cleanup(proxy.Close)
+
+	consulAddr := "consul.example.test:8500"
+
+	cases := map[string]struct {
+		noProxy         string
+		expectedProxied []string
+	}{
+		"requests are routed through HTTP_PROXY": {
+			expectedProxied: []string{consulAddr},
+		},
+		"NO_PROXY bypasses the proxy": {
+			noProxy: "consul.example.test",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			proxiedHosts = nil
+			t.Setenv("HTTP_PROXY", proxy.URL)
+			t.Setenv("NO_PROXY", c.noProxy)
+
+			cfg := &Config{}
+			client, err := cfg.NewConsulAPIClient(&api.Config{Address: consulAddr})
+			require.NoError(t, err)
+
+			leader, err := client.Status().Leader()
+			if c.expectedProxied == nil {
+				// The unresolvable address is dialed directly and fails.
+				require.Error(t, err)
+				require.Empty(t, proxiedHosts)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, "10.0.0.1:8300", leader)
+				require.Equal(t, c.expectedProxied, proxiedHosts)
+			}
+		})
+	}
+}
+
 func writeCAFile(t *testing.T) *os.File {
 	caFile, err := os.CreateTemp("", "")
 	require.NoError(t, err)
@@ -524,3 +840,66 @@ func writeCAFile(t *testing.T) *os.File {
 	require.NoError(t, err)
 	return caFile
 }
+
+func TestGetHealthSyncContainers(t *testing.T) {
+	taskMeta := awsutil.ECSTaskMeta{
+		Containers: []awsutil.ECSTaskMetaContainer{
+			{Name: "app-web"},
+			{Name: "app-worker"},
+			{Name: "sidecar"},
+			{Name: ConsulDataplaneContainerName},
+		},
+	}
+	cases := map[string]struct {
+		healthSyncContainers   []string
+		nonEssentialContainers []string
+		expected               []string
+	}{
+		"none": {},
+		"literal names": {
+			healthSyncContainers: []string{"sidecar", "missing"},
+			expected:             []string{"sidecar", "missing"},
+		},
+		"wildcard": {
+			healthSyncContainers: []string{"app-*"},
+			expected:             []string{"app-web", "app-worker"},
+		},
+		"wildcard does not match consul-dataplane": {
+			healthSyncContainers: []string{"*"},
+			expected:             []string{"app-web", "app-worker", "sidecar"},
+		},
+		"literal and wildcard are deduplicated": {
+			healthSyncContainers: []string{"app-worker", "app-*"},
+			expected:             []string{"app-worker", "app-web"},
+		},
+		"non-matching pattern": {
+			healthSyncContainers: []string{"worker-?"},
+		},
+		"non-essential containers are included": {
+			healthSyncContainers:   []string{"app-*"},
+			nonEssentialContainers: []string{"sidecar"},
+			expected:               []string{"app-web", "app-worker", "sidecar"},
+		},
+		"non-essential containers only": {
+			nonEssentialContainers: []string{"sidecar"},
+			expected:               []string{"sidecar"},
+		},
+		"non-essential containers are deduplicated with patterns": {
+			healthSyncContainers:   []string{"*"},
+			nonEssentialContainers: []string{"sidecar"},
+			expected:               []string{"app-web", "app-worker", "sidecar"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := &Config{
+				HealthSyncContainers:             c.healthSyncContainers,
+				HealthSyncNonEssentialContainers: c.nonEssentialContainers,
+			}
+			require.Equal(t, c.expected, cfg.GetHealthSyncContainers(taskMeta))
+			for _, name := range c.expected {
+				require.Equal(t, name == "sidecar" && len(c.nonEssentialContainers) > 0, cfg.IsNonEssentialHealthSyncContainer(name))
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"encoding/json"
+)
+
+// Effective returns a copy of the config with the defaults that consul-ecs
+// applies when it uses the config filled in, such as the proxy and gateway
+// ports and the TLS settings inherited from `consulServers.defaults`.
+// Defaults that depend on the task, such as the node name, are left unset.
+func (c *Config) Effective() (*Config, error) {
+	// Round trip through JSON for a deep copy, so that the defaults are not
+	// applied to c. The defaults applied when unmarshalling are kept.
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var e Config
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+
+	e.ConsulServers.applyDefaults()
+	e.Mesh.applyDefaults(e.BootstrapDir)
+
+	if e.IsGateway() {
+		e.Gateway.SourceTag = e.Gateway.GetSourceTag()
+		if e.Gateway.LanAddress == nil {
+			e.Gateway.LanAddress = &GatewayAddress{}
+		}
+		if e.Gateway.LanAddress.Port == 0 {
+			e.Gateway.LanAddress.Port = DefaultGatewayPort
+		}
+		e.Gateway.HealthCheckPort = GetHealthCheckPort(e.Gateway.HealthCheckPort)
+		return &e, nil
+	}
+
+	e.Service.SourceTag = e.Service.GetSourceTag()
+	if e.SidecarEnabled() {
+		if e.Proxy == nil {
+			e.Proxy = &AgentServiceConnectProxyConfig{}
+		}
+		e.Proxy.PublicListenerPort = e.Proxy.GetPublicListenerPort()
+		e.Proxy.HealthCheckPort = GetHealthCheckPort(e.Proxy.HealthCheckPort)
+	}
+	return &e, nil
+}
+
+// applyDefaults resolves the gRPC and HTTP settings that fall back to
+// `consulServers.defaults`. Fields that conflict with a configured
+// alternative, such as a CA cert PEM, are left unset.
+func (c *ConsulServers) applyDefaults() {
+	c.HTTPTimeout = Duration(c.GetHTTPTimeout())
+
+	grpc := c.GetGRPCTLSSettings()
+	c.GRPC.EnableTLS = &grpc.Enabled
+	if c.GRPC.CaCertSecretARN == "" {
+		c.GRPC.CaCertFile = grpc.CaCertFile
+	}
+	if grpc.Enabled {
+		c.GRPC.TLSServerName = grpc.TLSServerName
+	}
+
+	http := c.getHTTPTLSSettings()
+	c.HTTP.EnableTLS = &http.Enabled
+	if c.HTTP.CaCertPem == "" {
+		c.HTTP.CaCertFile = http.CaCertFile
+	}
+	if c.HTTP.EnableHTTPS {
+		c.HTTP.TLSServerName = http.TLSServerName
+	}
+}
+
+// applyDefaults sets the mesh settings that are defaulted when they are used.
+func (m *Mesh) applyDefaults(bootstrapDir string) {
+	m.BootstrapFileMode = FileMode(m.GetBootstrapFileMode())
+	m.HealthSyncInterval = Duration(m.GetHealthSyncInterval())
+
+	copyBinary := m.GetCopyBinary()
+	m.CopyBinary = &copyBinary
+	if copyBinary {
+		m.BinaryDestination = m.GetBinaryDestination(bootstrapDir)
+	}
+
+	sidecarEnabled := m.GetSidecarEnabled()
+	if m.Sidecar == nil {
+		m.Sidecar = &Sidecar{}
+	}
+	m.Sidecar.Enabled = &sidecarEnabled
+
+	rollbackOnFailure := m.GetRollbackOnFailure()
+	m.RollbackOnFailure = &rollbackOnFailure
+
+	if m.ServiceIDStrategy == "" && m.ServiceIDSuffix == "" {
+		m.ServiceIDStrategy = ServiceIDStrategyTaskID
+	}
+}
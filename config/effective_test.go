@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-ecs/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffective(t *testing.T) {
+	t.Run("service defaults", func(t *testing.T) {
+		cfg, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, "service": {"port": 8080}}`)
+		require.NoError(t, err)
+
+		effective, err := cfg.Effective()
+		require.NoError(t, err)
+
+		require.Equal(t, &AgentServiceConnectProxyConfig{
+			PublicListenerPort: DefaultPublicListenerPort,
+			HealthCheckPort:    DefaultProxyHealthCheckPort,
+		}, effective.Proxy)
+		require.Equal(t, DefaultSourceTag, effective.Service.SourceTag)
+
+		require.Equal(t, testutil.BoolPtr(true), effective.ConsulServers.GRPC.EnableTLS)
+		require.Equal(t, testutil.BoolPtr(true), effective.ConsulServers.HTTP.EnableTLS)
+		require.Equal(t, Duration(defaultHTTPTimeout), effective.ConsulServers.HTTPTimeout)
+
+		require.Equal(t, FileMode(defaultBootstrapFileMode), effective.Mesh.BootstrapFileMode)
+		require.Equal(t, Duration(defaultHealthSyncInterval), effective.Mesh.HealthSyncInterval)
+		require.Equal(t, testutil.BoolPtr(true), effective.Mesh.CopyBinary)
+		require.Equal(t, "/consul/consul-ecs", effective.Mesh.BinaryDestination)
+		require.Equal(t, &Sidecar{Enabled: testutil.BoolPtr(true)}, effective.Mesh.Sidecar)
+		require.Equal(t, testutil.BoolPtr(true), effective.Mesh.RollbackOnFailure)
+		require.Equal(t, ServiceIDStrategyTaskID, effective.Mesh.ServiceIDStrategy)
+
+		// The parsed config is not changed.
+		require.Nil(t, cfg.Proxy)
+		require.Nil(t, cfg.Mesh.Sidecar)
+	})
+
+	t.Run("gateway defaults", func(t *testing.T) {
+		cfg, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, "gateway": {"kind": "mesh-gateway"}}`)
+		require.NoError(t, err)
+
+		effective, err := cfg.Effective()
+		require.NoError(t, err)
+
+		require.Equal(t, &GatewayAddress{Port: DefaultGatewayPort}, effective.Gateway.LanAddress)
+		require.Equal(t, DefaultProxyHealthCheckPort, effective.Gateway.HealthCheckPort)
+		require.Equal(t, DefaultSourceTag, effective.Gateway.SourceTag)
+		require.Nil(t, effective.Proxy)
+		require.Nil(t, cfg.Gateway.LanAddress)
+	})
+
+	t.Run("inherited TLS settings", func(t *testing.T) {
+		cfg, err := parse(`{"bootstrapDir": "/consul", "service": {"port": 8080}, "consulServers": {
+			"hosts": "consul.dc1",
+			"defaults": {"caCertFile": "/consul/ca.pem", "tlsServerName": "consul.dc1"},
+			"grpc": {"tls": false}
+		}}`)
+		require.NoError(t, err)
+
+		effective, err := cfg.Effective()
+		require.NoError(t, err)
+
+		grpc := effective.ConsulServers.GRPC
+		require.Equal(t, testutil.BoolPtr(false), grpc.EnableTLS)
+		require.Equal(t, "/consul/ca.pem", grpc.CaCertFile)
+		require.Empty(t, grpc.TLSServerName)
+
+		http := effective.ConsulServers.HTTP
+		require.Equal(t, testutil.BoolPtr(true), http.EnableTLS)
+		require.Equal(t, "/consul/ca.pem", http.CaCertFile)
+		require.Equal(t, "consul.dc1", http.TLSServerName)
+	})
+
+	t.Run("configured values are kept", func(t *testing.T) {
+		cfg, err := FromFile("resources/test_extensive_config.json")
+		require.NoError(t, err)
+
+		effective, err := cfg.Effective()
+		require.NoError(t, err)
+
+		require.Equal(t, cfg.Service, effective.Service)
+		require.Equal(t, cfg.Proxy, effective.Proxy)
+		require.Equal(t, Duration(15*time.Second), effective.Mesh.HealthSyncInterval)
+		require.Equal(t, cfg.Mesh.Sidecar, effective.Mesh.Sidecar)
+	})
+}
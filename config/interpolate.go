@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// interpolationPattern matches `${VAR}`, `${VAR:-default}` and the `$$` escape.
+var interpolationPattern = regexp.MustCompile(`\$(\$|\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\})`)
+
+// opaqueConfigPattern matches the paths of the opaque proxy config maps, which
+// are passed to Envoy as written: proxy.config, proxy.upstreams[*].config and
+// gateway.proxy.config.
+var opaqueConfigPattern = regexp.MustCompile(`^(proxy|proxy\.upstreams\.[0-9]+|gateway\.proxy)\.config$`)
+
+// interpolate expands environment variable references in the string values of
+// the encoded config, such as meta values and tags. `${VAR}` is replaced with the
+// value of VAR and is an error if VAR is not set. `${VAR:-default}` is replaced
+// with default if VAR is unset or empty. `$$` is replaced with a literal `$`.
+// Object keys, non-string values and the opaque proxy config maps are not changed.
+//
+// The config is returned unchanged if it is not valid JSON, so that the schema
+// validation can report the error.
+func interpolate(encodedConfig string) (string, error) {
+	if !strings.Contains(encodedConfig, "$") {
+		return encodedConfig, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(encodedConfig))
+	// Keep numbers as they are written rather than converting them to float64.
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return encodedConfig, nil
+	}
+
+	var result error
+	raw = interpolateValue("(root)", raw, &result)
+	if result != nil {
+		return "", result
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(raw); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func interpolateValue(path string, v interface{}, result *error) interface{} {
+	if opaqueConfigPattern.MatchString(path) {
+		return v
+	}
+	switch v := v.(type) {
+	case string:
+		return interpolateString(path, v, result)
+	case map[string]interface{}:
+		// Sort the keys so that errors are reported in a stable order.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v[k] = interpolateValue(joinPath(path, k), v[k], result)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = interpolateValue(joinPath(path, strconv.Itoa(i)), v[i], result)
+		}
+	}
+	return v
+}
+
+func interpolateString(path, s string, result *error) string {
+	return interpolationPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		groups := interpolationPattern.FindStringSubmatch(match)
+		name, hasDefault, defaultValue := groups[2], groups[3] != "", groups[4]
+
+		value, ok := os.LookupEnv(name)
+		if hasDefault && value == "" {
+			return defaultValue
+		}
+		if !ok {
+			*result = multierror.Append(*result, fmt.Errorf("%s: environment variable %s is not set", path, name))
+		}
+		return value
+	})
+}
+
+func joinPath(path, key string) string {
+	if path == "(root)" {
+		return key
+	}
+	return path + "." + key
+}
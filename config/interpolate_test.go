@@ -0,0 +1,98 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("TEST_TASK_ARN", "arn:aws:ecs:us-east-1:123456789:task/test/abcdef")
+	t.Setenv("TEST_REGION", "us-east-1")
+	t.Setenv("TEST_EMPTY", "")
+
+	cases := map[string]struct {
+		config   string
+		expected string
+		expError string
+	}{
+		"no references": {
+			config:   `{"service": {"port": 8080}}`,
+			expected: `{"service": {"port": 8080}}`,
+		},
+		"resolved": {
+			config:   `{"service": {"meta": {"task": "${TEST_TASK_ARN}"}, "tags": ["region-${TEST_REGION}"]}}`,
+			expected: `{"service": {"meta": {"task": "arn:aws:ecs:us-east-1:123456789:task/test/abcdef"}, "tags": ["region-us-east-1"]}}`,
+		},
+		"defaulted": {
+			config:   `{"service": {"meta": {"env": "${TEST_UNSET:-dev}", "empty": "${TEST_EMPTY:-none}", "blank": "${TEST_UNSET:-}"}}}`,
+			expected: `{"service": {"meta": {"env": "dev", "empty": "none", "blank": ""}}}`,
+		},
+		"set but empty": {
+			config:   `{"service": {"meta": {"empty": "${TEST_EMPTY}"}}}`,
+			expected: `{"service": {"meta": {"empty": ""}}}`,
+		},
+		"escaped": {
+			config:   `{"service": {"meta": {"literal": "$${TEST_REGION}", "dollar": "$5"}}}`,
+			expected: `{"service": {"meta": {"literal": "${TEST_REGION}", "dollar": "$5"}}}`,
+		},
+		"keys, numbers and booleans are not changed": {
+			config:   `{"service": {"meta": {"${TEST_REGION}": "${TEST_REGION}"}, "port": 8080, "enableTagOverride": true}, "price": 1.50}`,
+			expected: `{"service": {"meta": {"${TEST_REGION}": "us-east-1"}, "port": 8080, "enableTagOverride": true}, "price": 1.50}`,
+		},
+		"opaque proxy config is not changed": {
+			config:   `{"proxy": {"config": {"envoy_extra": "$${TEST_REGION} ${TEST_UNSET}"}, "upstreams": [{"destinationName": "${TEST_REGION}", "config": {"x": "$$"}}]}, "gateway": {"proxy": {"config": {"x": "${TEST_REGION}"}}}}`,
+			expected: `{"proxy": {"config": {"envoy_extra": "$${TEST_REGION} ${TEST_UNSET}"}, "upstreams": [{"destinationName": "us-east-1", "config": {"x": "$$"}}]}, "gateway": {"proxy": {"config": {"x": "${TEST_REGION}"}}}}`,
+		},
+		"missing": {
+			config:   `{"service": {"meta": {"a": "${TEST_UNSET_A}"}, "tags": ["${TEST_UNSET_B}"]}}`,
+			expError: "service.meta.a: environment variable TEST_UNSET_A is not set",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			result, err := interpolate(c.config)
+			if c.expError != "" {
+				require.ErrorContains(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, c.expected, result)
+		})
+	}
+
+	t.Run("invalid json is left to schema validation", func(t *testing.T) {
+		config := `{"service": "${TEST_REGION}"`
+		result, err := interpolate(config)
+		require.NoError(t, err)
+		require.Equal(t, config, result)
+	})
+
+	t.Run("all missing variables are reported", func(t *testing.T) {
+		_, err := interpolate(`{"service": {"meta": {"a": "${TEST_UNSET_A}"}, "tags": ["${TEST_UNSET_B}"]}}`)
+		require.ErrorContains(t, err, "service.meta.a: environment variable TEST_UNSET_A is not set")
+		require.ErrorContains(t, err, "service.tags.0: environment variable TEST_UNSET_B is not set")
+	})
+}
+
+func TestParseInterpolation(t *testing.T) {
+	t.Setenv("TEST_SERVICE_NAME", "frontend")
+	t.Setenv("TEST_VERSION", "1.2.3")
+
+	cfg, err := parse(`{
+  "bootstrapDir": "/consul/",
+  "consulServers": {"hosts": "consul.dc1"},
+  "service": {
+    "name": "${TEST_SERVICE_NAME}",
+    "port": 8080,
+    "meta": {"version": "${TEST_VERSION}", "env": "${TEST_ENV:-dev}"}
+  }
+}`)
+	require.NoError(t, err)
+	require.Equal(t, "frontend", cfg.Service.Name)
+	require.Equal(t, 8080, cfg.Service.Port)
+	require.Equal(t, map[string]string{"version": "1.2.3", "env": "dev"}, cfg.Service.Meta)
+}
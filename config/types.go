@@ -5,8 +5,22 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
-
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/hashicorp/consul-ecs/awsutil"
 	"github.com/hashicorp/consul/api"
 )
 
@@ -26,12 +40,33 @@ const (
 	// DefaultProxyHealthCheckPort is the default HTTP health check port for the proxy.
 	DefaultProxyHealthCheckPort = 22000
 
+	// ServiceIDStrategyTaskID and ServiceIDStrategyTaskARNHash are the supported strategies
+	// for the suffix of the service and proxy IDs.
+	ServiceIDStrategyTaskID      = "taskId"
+	ServiceIDStrategyTaskARNHash = "taskArnHash"
+
+	// proxyConfigBindAddress is the Envoy proxy config key for the address
+	// that the public listener binds to.
+	proxyConfigBindAddress = "bind_address"
+
+	// taskARNHashLength is the number of hex characters of the task ARN hash used as the ID suffix.
+	taskARNHashLength = 16
+
+	// DefaultSourceTag is the default value of the `source` meta on services registered by consul-ecs.
+	DefaultSourceTag = "consul-ecs"
+
 	// TaggedAddressLAN is the map key for LAN tagged addresses.
 	TaggedAddressLAN = "lan"
 
 	// TaggedAddressWAN is the map key for WAN tagged addresses.
 	TaggedAddressWAN = "wan"
 
+	// TaggedAddressLANIPv4 and TaggedAddressLANIPv6 are the map keys for the
+	// LAN tagged addresses of each IP version of the task. Additional port
+	// names cannot contain underscores, so they cannot collide with these keys.
+	TaggedAddressLANIPv4 = "lan_ipv4"
+	TaggedAddressLANIPv6 = "lan_ipv6"
+
 	// Name of the dataplane's container
 	ConsulDataplaneContainerName = "consul-dataplane"
 
@@ -44,19 +79,25 @@ const (
 	GetEntityBodyHeader    string = "X-Consul-IAM-GetEntity-Body"
 
 	SyntheticNode string = "synthetic-node"
+
+	LogFormatText = "text"
+	LogFormatJSON = "json"
 )
 
 // Config is the top-level config object.
 type Config struct {
-	BootstrapDir         string                          `json:"bootstrapDir"`
-	ConsulLogin          ConsulLogin                     `json:"consulLogin"`
-	HealthSyncContainers []string                        `json:"healthSyncContainers,omitempty"`
-	LogLevel             string                          `json:"logLevel,omitempty"`
-	Proxy                *AgentServiceConnectProxyConfig `json:"proxy"`
-	Gateway              *GatewayRegistration            `json:"gateway,omitempty"`
-	Service              ServiceRegistration             `json:"service"`
-	ConsulServers        ConsulServers                   `json:"consulServers"`
-	Controller           Controller                      `json:"controller"`
+	BootstrapDir                     string                          `json:"bootstrapDir"`
+	ConsulLogin                      ConsulLogin                     `json:"consulLogin"`
+	HealthSyncContainers             []string                        `json:"healthSyncContainers,omitempty"`
+	HealthSyncNonEssentialContainers []string                        `json:"healthSyncNonEssentialContainers,omitempty"`
+	LogLevel                         string                          `json:"logLevel,omitempty"`
+	LogFormat                        string                          `json:"logFormat,omitempty"`
+	Proxy                            *AgentServiceConnectProxyConfig `json:"proxy"`
+	Gateway                          *GatewayRegistration            `json:"gateway,omitempty"`
+	Service                          ServiceRegistration             `json:"service"`
+	ConsulServers                    ConsulServers                   `json:"consulServers"`
+	Controller                       Controller                      `json:"controller"`
+	Mesh                             Mesh                            `json:"mesh"`
 }
 
 // ConsulLogin configures login options for the Consul IAM auth method.
@@ -109,6 +150,22 @@ type ConsulServers struct {
 	Defaults        DefaultSettings `json:"defaults"`
 	GRPC            GRPCSettings    `json:"grpc"`
 	HTTP            HTTPSettings    `json:"http"`
+	HTTPTimeout     Duration        `json:"httpTimeout"`
+
+	// Datacenter is the Consul datacenter that the service and proxy are
+	// registered in. Defaults to the datacenter of the Consul servers.
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// CloudMap discovers the Consul servers from a Cloud Map service,
+	// instead of Hosts.
+	CloudMap *CloudMap `json:"cloudMap,omitempty"`
+}
+
+// CloudMap identifies the AWS Cloud Map service that the Consul servers are
+// registered with, such as by ECS service discovery.
+type CloudMap struct {
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
 }
 
 // UnmarshalJSON is a custom unmarshaller that assigns defaults to certain fields
@@ -159,6 +216,14 @@ func (c *ConsulServers) UnmarshalJSON(data []byte) error {
 		c.HTTP = *alias.RawHTTPSettings
 	}
 
+	if c.GRPC.TLSServerName != "" && !c.GetGRPCTLSSettings().Enabled {
+		return fmt.Errorf("consulServers.grpc.tlsServerName requires TLS to be enabled for gRPC")
+	}
+
+	if c.CloudMap != nil && c.Hosts != "" {
+		return fmt.Errorf("consulServers.hosts and consulServers.cloudMap are mutually exclusive")
+	}
+
 	return nil
 }
 
@@ -201,6 +266,10 @@ type GRPCSettings struct {
 	CaCertFile    string `json:"caCertFile"`
 	EnableTLS     *bool  `json:"tls"`
 	TLSServerName string `json:"tlsServerName"`
+
+	// CaCertSecretARN is the ARN of a Secrets Manager secret that contains
+	// the PEM encoded CA cert for gRPC, used instead of CaCertFile.
+	CaCertSecretARN string `json:"caCertSecretArn,omitempty"`
 }
 
 // UnmarshalJSON is a custom unmarshaller that assigns defaults to certain fields
@@ -224,6 +293,16 @@ func (g *GRPCSettings) UnmarshalJSON(data []byte) error {
 	} else {
 		g.Port = *alias.RawPort
 	}
+
+	if g.CaCertSecretARN != "" {
+		parsed, err := arn.Parse(g.CaCertSecretARN)
+		if err != nil || parsed.Service != secretsmanager.ServiceName {
+			return fmt.Errorf("consulServers.grpc.caCertSecretArn %q must be a Secrets Manager secret ARN", g.CaCertSecretARN)
+		}
+		if g.CaCertFile != "" {
+			return fmt.Errorf("consulServers.grpc.caCertFile and consulServers.grpc.caCertSecretArn are mutually exclusive")
+		}
+	}
 	return nil
 }
 
@@ -235,6 +314,10 @@ type HTTPSettings struct {
 	CaCertFile    string `json:"caCertFile"`
 	EnableTLS     *bool  `json:"tls"`
 	TLSServerName string `json:"tlsServerName"`
+
+	// CaCertPem is the PEM encoded CA certificate for the Consul HTTP API.
+	// It takes precedence over the CA certificate files.
+	CaCertPem string `json:"caCertPem,omitempty"`
 }
 
 // UnmarshalJSON is a custom unmarshaller that assigns defaults to certain fields
@@ -266,6 +349,18 @@ func (h *HTTPSettings) UnmarshalJSON(data []byte) error {
 	} else {
 		h.Port = *alias.RawPort
 	}
+
+	if h.CaCertPem != "" {
+		if !h.EnableHTTPS {
+			return fmt.Errorf("consulServers.http.caCertPem requires HTTPS to be enabled")
+		}
+		if h.CaCertFile != "" {
+			return fmt.Errorf("consulServers.http.caCertFile and consulServers.http.caCertPem are mutually exclusive")
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM([]byte(h.CaCertPem)) {
+			return fmt.Errorf("consulServers.http.caCertPem must contain a PEM encoded certificate")
+		}
+	}
 	return nil
 }
 
@@ -274,6 +369,14 @@ type Controller struct {
 	IAMRolePath       string `json:"iamRolePath"`
 	PartitionsEnabled bool   `json:"partitionsEnabled"`
 	Partition         string `json:"partition"`
+
+	// DescribeTasksConcurrency is the number of concurrent DescribeTasks calls
+	// made when listing the tasks in the cluster. Defaults to 4 if zero.
+	DescribeTasksConcurrency int `json:"describeTasksConcurrency,omitempty"`
+
+	// RequiredTags are the tags, in addition to the mesh tag, that a task
+	// must have to be managed by the controller.
+	RequiredTags map[string]string `json:"requiredTags,omitempty"`
 }
 
 // UnmarshalJSON is a custom unmarshaller that assigns defaults to certain fields
@@ -305,13 +408,290 @@ func (c *Controller) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Mesh configures the options for the consul-ecs mesh-init and health-sync commands.
+type Mesh struct {
+	// BootstrapTimeout bounds the total time spent registering the service and
+	// proxy with Consul, including requests in flight. Zero means registration
+	// is retried indefinitely.
+	BootstrapTimeout Duration `json:"bootstrapTimeout,omitempty"`
+
+	// DeregisterStaleInstances deregisters instances of this service left in the
+	// catalog by stopped tasks, once this task's service and proxy are registered.
+	DeregisterStaleInstances bool `json:"deregisterStaleInstances,omitempty"`
+
+	// NodeName is the Consul node that the service and proxy are registered with.
+	// Defaults to the ECS cluster ARN.
+	NodeName string `json:"nodeName,omitempty"`
+
+	// HealthSyncInterval is how often health-sync syncs the ECS container
+	// health to the Consul checks. Defaults to 10s.
+	HealthSyncInterval Duration `json:"healthSyncInterval,omitempty"`
+
+	// BootstrapFileMode is the file mode of the dataplane config, CA cert and
+	// summary files written to the bootstrap directory. Defaults to 0444.
+	BootstrapFileMode FileMode `json:"bootstrapFileMode,omitempty"`
+
+	// NodeMeta is additional meta for the Consul node. It is merged with the
+	// synthetic node marker, which cannot be overridden.
+	NodeMeta map[string]string `json:"nodeMeta,omitempty"`
+
+	// CopyBinary controls whether mesh-init copies the consul-ecs binary to the
+	// bootstrap directory. Defaults to true. The `netdial` health check and the
+	// entrypoint helpers run the copied binary, so they do not work when disabled.
+	CopyBinary *bool `json:"copyBinary,omitempty"`
+
+	// BinaryDestination is the path that the consul-ecs binary is copied to.
+	// A relative path is relative to the bootstrap directory.
+	// Defaults to consul-ecs in the bootstrap directory.
+	BinaryDestination string `json:"binaryDestination,omitempty"`
+
+	// CreatePartition creates the admin partition of the service or gateway
+	// before registering, if it does not exist [Consul Enterprise].
+	CreatePartition bool `json:"createPartition,omitempty"`
+
+	// CreateNamespace creates the namespace of the service or gateway before
+	// registering, if it does not exist [Consul Enterprise].
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+
+	// WriteProxyDefaults configures the global proxy-defaults config entry
+	// that mesh-init writes, if the entry does not already set these fields.
+	WriteProxyDefaults *ProxyDefaults `json:"writeProxyDefaults,omitempty"`
+
+	// ServiceIDStrategy is how the suffix of the service and proxy IDs is derived
+	// from the task, either `taskId` or `taskArnHash`. Defaults to `taskId`.
+	ServiceIDStrategy string `json:"serviceIdStrategy,omitempty"`
+
+	// ServiceIDSuffix is a fixed suffix for the service and proxy IDs, used
+	// instead of a suffix derived from the task.
+	ServiceIDSuffix string `json:"serviceIdSuffix,omitempty"`
+
+	// Sidecar configures the sidecar proxy of the service.
+	Sidecar *Sidecar `json:"sidecar,omitempty"`
+
+	// RollbackOnFailure deregisters the service and proxy when mesh-init fails
+	// after registering them. Defaults to true.
+	RollbackOnFailure *bool `json:"rollbackOnFailure,omitempty"`
+
+	// Retry configures the backoff between service and proxy registration
+	// attempts. Registrations are retried every second when it is unset.
+	Retry *Retry `json:"retry,omitempty"`
+}
+
+// Retry configures an exponential backoff between registration attempts.
+type Retry struct {
+	// InitialInterval is the delay before the first retry. Defaults to 1s.
+	InitialInterval Duration `json:"initialInterval,omitempty"`
+
+	// MaxInterval caps the delay between retries. Defaults to 60s.
+	MaxInterval Duration `json:"maxInterval,omitempty"`
+
+	// Multiplier is the factor that the delay grows by after each retry.
+	// Defaults to 1.5.
+	Multiplier float64 `json:"multiplier,omitempty"`
+}
+
+// IsSet returns whether any of the backoff parameters are set.
+func (r *Retry) IsSet() bool {
+	return r != nil && (r.InitialInterval != 0 || r.MaxInterval != 0 || r.Multiplier != 0)
+}
+
+// Sidecar configures the sidecar proxy of the service.
+type Sidecar struct {
+	// Enabled controls whether mesh-init registers a sidecar proxy for the
+	// service and writes the dataplane config. Defaults to true. Disable it
+	// for services that integrate with the mesh natively.
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// ProxyDefaults are the fields of the global proxy-defaults config entry
+// that mesh-init can write.
+type ProxyDefaults struct {
+	MeshGateway MeshGatewayConfig `json:"meshGateway"`
+}
+
+// UnmarshalJSON is a custom unmarshaller that validates certain fields
+func (m *Mesh) UnmarshalJSON(data []byte) error {
+	type Alias Mesh
+	alias := (*Alias)(m)
+	if err := json.Unmarshal(data, alias); err != nil {
+		return err
+	}
+
+	if m.HealthSyncInterval != 0 && time.Duration(m.HealthSyncInterval) < minHealthSyncInterval {
+		return fmt.Errorf("mesh.healthSyncInterval must be at least %s", minHealthSyncInterval)
+	}
+
+	if mode := m.BootstrapFileMode; mode != 0 {
+		if mode&0444 == 0 {
+			return fmt.Errorf("mesh.bootstrapFileMode %s must be readable", mode)
+		}
+		if mode&0002 != 0 {
+			return fmt.Errorf("mesh.bootstrapFileMode %s must not be world-writable", mode)
+		}
+	}
+
+	if m.BinaryDestination != "" && strings.HasSuffix(m.BinaryDestination, "/") {
+		return fmt.Errorf("mesh.binaryDestination %q must be a file path, not a directory", m.BinaryDestination)
+	}
+
+	if r := m.Retry; r != nil {
+		if r.InitialInterval < 0 {
+			return fmt.Errorf("mesh.retry.initialInterval must not be negative")
+		}
+		if r.MaxInterval < 0 {
+			return fmt.Errorf("mesh.retry.maxInterval must not be negative")
+		}
+		if r.InitialInterval > 0 && r.MaxInterval > 0 && r.MaxInterval < r.InitialInterval {
+			return fmt.Errorf("mesh.retry.maxInterval must be at least mesh.retry.initialInterval")
+		}
+	}
+
+	if m.ServiceIDSuffix != "" && m.ServiceIDStrategy != "" {
+		return fmt.Errorf("mesh.serviceIdStrategy and mesh.serviceIdSuffix are mutually exclusive")
+	}
+
+	for key := range m.NodeMeta {
+		if key == SyntheticNode {
+			return fmt.Errorf("mesh.nodeMeta: key %q is reserved", key)
+		}
+		if strings.HasPrefix(key, reservedNodeMetaPrefix) {
+			return fmt.Errorf("mesh.nodeMeta: key %q must not use the reserved prefix %q", key, reservedNodeMetaPrefix)
+		}
+	}
+	return nil
+}
+
+// GetNodeName returns the Consul node name used for catalog registrations,
+// falling back to the cluster ARN when no node name is configured.
+func (m Mesh) GetNodeName(clusterARN string) string {
+	if m.NodeName != "" {
+		return m.NodeName
+	}
+	return clusterARN
+}
+
+// GetServiceIDSuffix returns the suffix of the service and proxy IDs for the task.
+func (m Mesh) GetServiceIDSuffix(taskMeta awsutil.ECSTaskMeta) string {
+	switch {
+	case m.ServiceIDSuffix != "":
+		return m.ServiceIDSuffix
+	case m.ServiceIDStrategy == ServiceIDStrategyTaskARNHash:
+		sum := sha256.Sum256([]byte(taskMeta.TaskARN))
+		return hex.EncodeToString(sum[:])[:taskARNHashLength]
+	default:
+		return taskMeta.TaskID()
+	}
+}
+
+// GetBootstrapFileMode returns the file mode for files written to the bootstrap directory.
+func (m Mesh) GetBootstrapFileMode() os.FileMode {
+	if m.BootstrapFileMode != 0 {
+		return os.FileMode(m.BootstrapFileMode)
+	}
+	return defaultBootstrapFileMode
+}
+
+// GetCopyBinary returns whether the consul-ecs binary is copied to the bootstrap directory.
+func (m Mesh) GetCopyBinary() bool {
+	return m.CopyBinary == nil || *m.CopyBinary
+}
+
+// GetSidecarEnabled returns whether mesh.sidecar.enabled allows a sidecar proxy
+// for the service. Use Config.SidecarEnabled, which also accounts for
+// Connect-native services.
+func (m Mesh) GetSidecarEnabled() bool {
+	return m.Sidecar == nil || m.Sidecar.Enabled == nil || *m.Sidecar.Enabled
+}
+
+// GetRollbackOnFailure returns whether mesh-init deregisters its registrations when it fails.
+func (m Mesh) GetRollbackOnFailure() bool {
+	return m.RollbackOnFailure == nil || *m.RollbackOnFailure
+}
+
+// GetBinaryDestination returns the path that the consul-ecs binary is copied to.
+func (m Mesh) GetBinaryDestination(bootstrapDir string) string {
+	if m.BinaryDestination == "" {
+		return path.Join(bootstrapDir, defaultBinaryName)
+	}
+	if path.IsAbs(m.BinaryDestination) {
+		return path.Clean(m.BinaryDestination)
+	}
+	return path.Join(bootstrapDir, m.BinaryDestination)
+}
+
+// GetHealthSyncInterval returns how often health-sync syncs check statuses.
+func (m Mesh) GetHealthSyncInterval() time.Duration {
+	if m.HealthSyncInterval > 0 {
+		return time.Duration(m.HealthSyncInterval)
+	}
+	return defaultHealthSyncInterval
+}
+
+// FileMode is a file permission mode that is represented in JSON as
+// an octal string, such as "0440".
+type FileMode os.FileMode
+
+func (m FileMode) String() string {
+	return fmt.Sprintf("%04o", uint32(m))
+}
+
+func (m FileMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *FileMode) UnmarshalJSON(data []byte) error {
+	var raw *string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*m = 0
+		return nil
+	}
+	parsed, err := strconv.ParseUint(*raw, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid file mode %q: %w", *raw, err)
+	}
+	if parsed > 0777 {
+		return fmt.Errorf("invalid file mode %q: only permission bits are allowed", *raw)
+	}
+	*m = FileMode(parsed)
+	return nil
+}
+
+// Duration is a time.Duration that is represented in JSON as a
+// Go duration string, such as "30s" or "2m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw *string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*d = 0
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(*raw)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", *raw, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
 // ServiceRegistration configures the Consul service registration.
 //
 // NOTE:
 // - The Kind and Id fields are set by mesh-init during service/proxy registration.
 // - The Address field excluded. The agent's address (task ip) should always be used in ECS.
-// - The Connect field is not supported:
-//   - No Connect-native support for now. We assume Envoy is used.
+// - The SocketPath field is used instead of the Port if the application listens on a Unix domain socket.
+// - The Connect field is only used to mark Connect-native services, with ConnectNative:
 //   - Proxy registration occurs in a separate request, so no need to inline the proxy config.
 //     See the SidecarProxyRegistration type.
 type ServiceRegistration struct {
@@ -323,6 +703,109 @@ type ServiceRegistration struct {
 	Weights           *AgentWeights     `json:"weights,omitempty"`
 	Namespace         string            `json:"namespace,omitempty"`
 	Partition         string            `json:"partition,omitempty"`
+	AdditionalPorts   []ServicePort     `json:"additionalPorts,omitempty"`
+	SourceTag         string            `json:"sourceTag,omitempty"`
+	AddLocalityTags   bool              `json:"addLocalityTags,omitempty"`
+
+	// SocketPath is the Unix domain socket that the application listens on,
+	// instead of a TCP port.
+	SocketPath string `json:"socketPath,omitempty"`
+
+	// ExpectedSubsets are the tag keys that service-resolver subsets of this
+	// service filter on, such as `version` for a `version:v2` tag. They are
+	// only used to warn about tags missing from the registration.
+	ExpectedSubsets []string `json:"expectedSubsets,omitempty"`
+
+	// ConnectNative registers the service as Connect-native, for applications
+	// that integrate with the mesh directly. No sidecar proxy is registered.
+	ConnectNative bool `json:"connectNative,omitempty"`
+}
+
+// ServicePort is an additional named port that the application listens on.
+type ServicePort struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+// isSet returns true if any field of the service registration is set. Empty
+// lists and maps, which are omitted when marshalled, are not set.
+func (r ServiceRegistration) isSet() (bool, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return false, err
+	}
+	empty, err := json.Marshal(ServiceRegistration{})
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(data, empty), nil
+}
+
+// UnmarshalJSON is a custom unmarshaller that validates certain fields
+func (r *ServiceRegistration) UnmarshalJSON(data []byte) error {
+	type Alias ServiceRegistration
+	alias := (*Alias)(r)
+	if err := json.Unmarshal(data, alias); err != nil {
+		return err
+	}
+
+	if r.SocketPath != "" && r.Port != 0 {
+		return fmt.Errorf("service.port and service.socketPath are mutually exclusive")
+	}
+
+	ports := map[int]bool{r.Port: true}
+	names := make(map[string]bool)
+	for _, p := range r.AdditionalPorts {
+		if p.Name == TaggedAddressLAN || p.Name == TaggedAddressWAN {
+			return fmt.Errorf("service.additionalPorts: name %q is reserved", p.Name)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("service.additionalPorts: duplicate name %q", p.Name)
+		}
+		if ports[p.Port] {
+			return fmt.Errorf("service.additionalPorts: duplicate port %d", p.Port)
+		}
+		names[p.Name] = true
+		ports[p.Port] = true
+	}
+	return nil
+}
+
+// MissingSubsetTags returns the expected subset keys that no tag provides.
+// A tag provides a key if it equals the key or has the form `<key>:<value>`.
+func (r *ServiceRegistration) MissingSubsetTags() []string {
+	var missing []string
+	for _, key := range r.ExpectedSubsets {
+		found := false
+		for _, tag := range r.Tags {
+			if tag == key || strings.HasPrefix(tag, key+":") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// AdditionalPortAddresses returns a tagged address for each additional port,
+// keyed by the port name, using the given address.
+func (r *ServiceRegistration) AdditionalPortAddresses(address string) map[string]api.ServiceAddress {
+	if len(r.AdditionalPorts) == 0 {
+		return nil
+	}
+	result := make(map[string]api.ServiceAddress, len(r.AdditionalPorts))
+	for _, p := range r.AdditionalPorts {
+		result[p.Name] = api.ServiceAddress{Address: address, Port: p.Port}
+	}
+	return result
+}
+
+// GetSourceTag returns the value of the `source` meta for the service.
+func (r *ServiceRegistration) GetSourceTag() string {
+	return getSourceTag(r.SourceTag)
 }
 
 func (r *ServiceRegistration) ToConsulType() *api.AgentService {
@@ -331,6 +814,7 @@ func (r *ServiceRegistration) ToConsulType() *api.AgentService {
 		Tags:              r.Tags,
 		Meta:              r.Meta,
 		Port:              r.Port,
+		SocketPath:        r.SocketPath,
 		Weights:           api.AgentWeights{},
 		EnableTagOverride: r.EnableTagOverride,
 		Namespace:         r.Namespace,
@@ -341,6 +825,10 @@ func (r *ServiceRegistration) ToConsulType() *api.AgentService {
 		result.Weights = r.Weights.ToConsulType()
 	}
 
+	if r.ConnectNative {
+		result.Connect = &api.AgentServiceConnect{Native: true}
+	}
+
 	return result
 }
 
@@ -373,17 +861,43 @@ func (w *AgentWeights) ToConsulType() api.AgentWeights {
 // For the proxy configuration (api.AgentServiceConnectProxyConfig in Consul),
 //   - The DestinationServiceName, DestinationServiceId, LocalServiceAddress, and LocalServicePort
 //     are all set by mesh-init, based on the service configuration.
-//   - The LocalServiceSocketPath is excluded, since it would conflict with the address/port set by mesh-init.
+//   - The LocalServiceSocketPath is set by mesh-init, instead of the LocalServicePort, when the
+//     service has a SocketPath.
 //   - Checks are excluded. mesh-init automatically configures useful checks for the proxy.
-//   - TProxy is not supported on ECS, so the Mode and TransparentProxy fields are excluded.
+//   - The Mode is set to transparent by mesh-init when TransparentProxy is configured.
 type AgentServiceConnectProxyConfig struct {
 	Config              map[string]interface{} `json:"config,omitempty"`
 	LocalServiceAddress string                 `json:"localServiceAddress,omitempty"`
 	PublicListenerPort  int                    `json:"publicListenerPort,omitempty"`
 	HealthCheckPort     int                    `json:"healthCheckPort,omitempty"`
-	Upstreams           []Upstream             `json:"upstreams,omitempty"`
-	MeshGateway         *MeshGatewayConfig     `json:"meshGateway,omitempty"`
-	Expose              *ExposeConfig          `json:"expose,omitempty"`
+
+	// PublicListenerBindAddress is the IP that the public listener binds to.
+	// It is passed to Envoy as the `bind_address` in the proxy config.
+	PublicListenerBindAddress string `json:"publicListenerBindAddress,omitempty"`
+
+	Upstreams        []Upstream              `json:"upstreams,omitempty"`
+	MeshGateway      *MeshGatewayConfig      `json:"meshGateway,omitempty"`
+	Expose           *ExposeConfig           `json:"expose,omitempty"`
+	TransparentProxy *TransparentProxyConfig `json:"transparentProxy,omitempty"`
+}
+
+// UnmarshalJSON is a custom unmarshaller that validates certain fields
+func (a *AgentServiceConnectProxyConfig) UnmarshalJSON(data []byte) error {
+	type Alias AgentServiceConnectProxyConfig
+	alias := (*Alias)(a)
+	if err := json.Unmarshal(data, alias); err != nil {
+		return err
+	}
+
+	if a.PublicListenerBindAddress != "" {
+		if net.ParseIP(a.PublicListenerBindAddress) == nil {
+			return fmt.Errorf("proxy.publicListenerBindAddress %q must be an IP address", a.PublicListenerBindAddress)
+		}
+		if _, ok := a.Config[proxyConfigBindAddress]; ok {
+			return fmt.Errorf("proxy.publicListenerBindAddress and proxy.config.%s are mutually exclusive", proxyConfigBindAddress)
+		}
+	}
+	return nil
 }
 
 func (a *AgentServiceConnectProxyConfig) ToConsulType() *api.AgentServiceConnectProxyConfig {
@@ -391,6 +905,14 @@ func (a *AgentServiceConnectProxyConfig) ToConsulType() *api.AgentServiceConnect
 		Config:    a.Config,
 		Upstreams: nil,
 	}
+	if a.PublicListenerBindAddress != "" {
+		// Copy the config so that the bind address is not added to the user's config.
+		result.Config = make(map[string]interface{}, len(a.Config)+1)
+		for k, v := range a.Config {
+			result.Config[k] = v
+		}
+		result.Config[proxyConfigBindAddress] = a.PublicListenerBindAddress
+	}
 	if a.LocalServiceAddress != "" {
 		result.LocalServiceAddress = a.LocalServiceAddress
 	}
@@ -400,6 +922,10 @@ func (a *AgentServiceConnectProxyConfig) ToConsulType() *api.AgentServiceConnect
 	if a.Expose != nil {
 		result.Expose = a.Expose.ToConsulType()
 	}
+	if a.TransparentProxy != nil {
+		result.Mode = api.ProxyModeTransparent
+		result.TransparentProxy = a.TransparentProxy.ToConsulType()
+	}
 	for _, u := range a.Upstreams {
 		result.Upstreams = append(result.Upstreams, u.ToConsulType())
 	}
@@ -431,6 +957,21 @@ type Upstream struct {
 	MeshGateway          *MeshGatewayConfig     `json:"meshGateway,omitempty"`
 }
 
+// UnmarshalJSON is a custom unmarshaller that validates certain fields
+func (u *Upstream) UnmarshalJSON(data []byte) error {
+	type Alias Upstream
+	alias := (*Alias)(u)
+	if err := json.Unmarshal(data, alias); err != nil {
+		return err
+	}
+
+	if u.LocalBindAddress != "" && net.ParseIP(u.LocalBindAddress) == nil {
+		return fmt.Errorf("proxy.upstreams: localBindAddress %q of upstream %q must be an IP address",
+			u.LocalBindAddress, u.DestinationName)
+	}
+	return nil
+}
+
 func (u *Upstream) ToConsulType() api.Upstream {
 	result := api.Upstream{
 		DestinationType:      u.DestinationType,
@@ -476,6 +1017,20 @@ func (e *ExposeConfig) ToConsulType() api.ExposeConfig {
 	return result
 }
 
+// TransparentProxyConfig configures transparent proxy mode for the sidecar proxy.
+// The task must redirect its traffic to the proxy, such as with iptables rules.
+type TransparentProxyConfig struct {
+	OutboundListenerPort int  `json:"outboundListenerPort"`
+	DialedDirectly       bool `json:"dialedDirectly,omitempty"`
+}
+
+func (t *TransparentProxyConfig) ToConsulType() *api.TransparentProxyConfig {
+	return &api.TransparentProxyConfig{
+		OutboundListenerPort: t.OutboundListenerPort,
+		DialedDirectly:       t.DialedDirectly,
+	}
+}
+
 // ExposePath are the paths to expose outside of connect. See ExposeConfig.
 type ExposePath struct {
 	ListenerPort  int    `json:"listenerPort,omitempty"`
@@ -504,6 +1059,22 @@ type GatewayRegistration struct {
 	Partition       string              `json:"partition,omitempty"`
 	Proxy           *GatewayProxyConfig `json:"proxy,omitempty"`
 	HealthCheckPort int                 `json:"healthCheckPort,omitempty"`
+	AssignPublicIP  bool                `json:"assignPublicIP,omitempty"`
+	Listeners       []IngressListener   `json:"listeners,omitempty"`
+	LinkedServices  []LinkedService     `json:"linkedServices,omitempty"`
+	SourceTag       string              `json:"sourceTag,omitempty"`
+}
+
+// GetSourceTag returns the value of the `source` meta for the gateway.
+func (g *GatewayRegistration) GetSourceTag() string {
+	return getSourceTag(g.SourceTag)
+}
+
+func getSourceTag(sourceTag string) string {
+	if sourceTag == "" {
+		return DefaultSourceTag
+	}
+	return sourceTag
 }
 
 func (g *GatewayRegistration) ToConsulType() *api.AgentService {
@@ -532,6 +1103,62 @@ func (p *GatewayProxyConfig) ToConsulType() *api.AgentServiceConnectProxyConfig
 	return &api.AgentServiceConnectProxyConfig{Config: p.Config}
 }
 
+// IngressListener configures a listener of an ingress gateway.
+type IngressListener struct {
+	Port     int              `json:"port"`
+	Protocol string           `json:"protocol,omitempty"`
+	Services []IngressService `json:"services"`
+}
+
+func (l *IngressListener) ToConsulType() api.IngressListener {
+	result := api.IngressListener{
+		Port:     l.Port,
+		Protocol: l.Protocol,
+	}
+	for _, svc := range l.Services {
+		result.Services = append(result.Services, svc.ToConsulType())
+	}
+	return result
+}
+
+// IngressService is a service exposed by an ingress gateway listener.
+type IngressService struct {
+	Name      string   `json:"name"`
+	Hosts     []string `json:"hosts,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+	Partition string   `json:"partition,omitempty"`
+}
+
+func (s *IngressService) ToConsulType() api.IngressService {
+	return api.IngressService{
+		Name:      s.Name,
+		Hosts:     s.Hosts,
+		Namespace: s.Namespace,
+		Partition: s.Partition,
+	}
+}
+
+// LinkedService is a service that a terminating gateway routes traffic to.
+type LinkedService struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	CAFile    string `json:"caFile,omitempty"`
+	CertFile  string `json:"certFile,omitempty"`
+	KeyFile   string `json:"keyFile,omitempty"`
+	SNI       string `json:"sni,omitempty"`
+}
+
+func (l *LinkedService) ToConsulType() api.LinkedService {
+	return api.LinkedService{
+		Name:      l.Name,
+		Namespace: l.Namespace,
+		CAFile:    l.CAFile,
+		CertFile:  l.CertFile,
+		KeyFile:   l.KeyFile,
+		SNI:       l.SNI,
+	}
+}
+
 type GatewayAddress struct {
 	Address string `json:"address,omitempty"`
 	Port    int    `json:"port,omitempty"`
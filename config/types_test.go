@@ -5,8 +5,12 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/consul-ecs/awsutil"
 	"github.com/hashicorp/consul-ecs/testutil"
 	"github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/require"
@@ -22,6 +26,30 @@ func TestProxyRegistrationToConsulType(t *testing.T) {
 	require.Equal(t, consulType, expectedConsulProxyRegistration)
 }
 
+func TestProxyConfigRoundTrip(t *testing.T) {
+	proxyConfig := `{
+		"envoy_prometheus_bind_addr": "0.0.0.0:9102",
+		"envoy_extra_static_clusters_json": "{}",
+		"local_connect_timeout_ms": 1000,
+		"nested": {
+			"list": [1, "two", {"three": true}],
+			"object": {"key": "value"}
+		}
+	}`
+
+	cfg, err := parse(fmt.Sprintf(`{
+		"bootstrapDir": "/consul/",
+		"consulServers": {"hosts": "consul.dc1"},
+		"service": {"port": 8080},
+		"proxy": {"config": %s}
+	}`, proxyConfig))
+	require.NoError(t, err)
+
+	registered, err := json.Marshal(cfg.Proxy.ToConsulType().Config)
+	require.NoError(t, err)
+	require.JSONEq(t, proxyConfig, string(registered))
+}
+
 func TestProxyRegistrationLocalServiceAddressToConsulType(t *testing.T) {
 	consulType := testProxyRegistrationLocalServiceAddress.ToConsulType()
 	require.Equal(t, consulType, expectedConsulProxyRegistrationLocalServiceAddress)
@@ -263,6 +291,172 @@ func TestConsulServersHoldsDefaultValues(t *testing.T) {
 	}
 }
 
+func TestConsulServersGRPCTLSServerNameRequiresTLS(t *testing.T) {
+	cases := map[string]struct {
+		data        string
+		expectedErr string
+	}{
+		"grpc tls enabled by defaults": {
+			data: `{
+				"hosts": "consul.dc1",
+				"grpc": {
+					"tlsServerName": "consul.dc1"
+				}
+			}`,
+		},
+		"grpc tls enabled explicitly": {
+			data: `{
+				"hosts": "consul.dc1",
+				"defaults": {
+					"tls": false
+				},
+				"grpc": {
+					"tls": true,
+					"tlsServerName": "consul.dc1"
+				}
+			}`,
+		},
+		"grpc tls disabled by defaults": {
+			data: `{
+				"hosts": "consul.dc1",
+				"defaults": {
+					"tls": false
+				},
+				"grpc": {
+					"tlsServerName": "consul.dc1"
+				}
+			}`,
+			expectedErr: "consulServers.grpc.tlsServerName requires TLS to be enabled for gRPC",
+		},
+		"grpc tls disabled explicitly": {
+			data: `{
+				"hosts": "consul.dc1",
+				"grpc": {
+					"tls": false,
+					"tlsServerName": "consul.dc1"
+				}
+			}`,
+			expectedErr: "consulServers.grpc.tlsServerName requires TLS to be enabled for gRPC",
+		},
+		"defaults tlsServerName with grpc tls disabled": {
+			data: `{
+				"hosts": "consul.dc1",
+				"defaults": {
+					"tlsServerName": "consul.dc1"
+				},
+				"grpc": {
+					"tls": false
+				}
+			}`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var cfg ConsulServers
+			err := json.Unmarshal([]byte(c.data), &cfg)
+			if c.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expectedErr)
+			}
+		})
+	}
+}
+
+func TestConsulServersCloudMap(t *testing.T) {
+	cases := map[string]struct {
+		data              string
+		expectedAddresses string
+		expectedErr       string
+	}{
+		"hosts": {
+			data:              `{"hosts": "consul.dc1"}`,
+			expectedAddresses: "consul.dc1",
+		},
+		"cloud map": {
+			data: `{
+				"cloudMap": {
+					"namespace": "consul.local",
+					"service": "consul-server"
+				}
+			}`,
+			expectedAddresses: " cloud-map-addresses -namespace consul.local -service consul-server",
+		},
+		"hosts and cloud map": {
+			data: `{
+				"hosts": "consul.dc1",
+				"cloudMap": {
+					"namespace": "consul.local",
+					"service": "consul-server"
+				}
+			}`,
+			expectedErr: "consulServers.hosts and consulServers.cloudMap are mutually exclusive",
+		},
+	}
+
+	executable, err := os.Executable()
+	require.NoError(t, err)
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var cfg ConsulServers
+			err := json.Unmarshal([]byte(c.data), &cfg)
+			if c.expectedErr != "" {
+				require.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			addresses, err := cfg.addresses()
+			require.NoError(t, err)
+			if cfg.CloudMap == nil {
+				require.Equal(t, c.expectedAddresses, addresses)
+			} else {
+				require.Equal(t, "exec="+executable+c.expectedAddresses, addresses)
+			}
+		})
+	}
+}
+
+func TestGRPCCaCertSecretARN(t *testing.T) {
+	cases := map[string]struct {
+		data        string
+		expectedErr string
+	}{
+		"secrets manager ARN": {
+			data: `{"caCertSecretArn": "arn:aws:secretsmanager:us-east-1:123456789:secret:consul-ca-cert"}`,
+		},
+		"not an ARN": {
+			data:        `{"caCertSecretArn": "consul-ca-cert"}`,
+			expectedErr: `consulServers.grpc.caCertSecretArn "consul-ca-cert" must be a Secrets Manager secret ARN`,
+		},
+		"ARN of another service": {
+			data:        `{"caCertSecretArn": "arn:aws:ssm:us-east-1:123456789:parameter/consul-ca-cert"}`,
+			expectedErr: `consulServers.grpc.caCertSecretArn "arn:aws:ssm:us-east-1:123456789:parameter/consul-ca-cert" must be a Secrets Manager secret ARN`,
+		},
+		"with a CA cert file": {
+			data: `{
+				"caCertFile": "/consul/ca-cert.pem",
+				"caCertSecretArn": "arn:aws:secretsmanager:us-east-1:123456789:secret:consul-ca-cert"
+			}`,
+			expectedErr: "consulServers.grpc.caCertFile and consulServers.grpc.caCertSecretArn are mutually exclusive",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var grpc GRPCSettings
+			err := json.Unmarshal([]byte(c.data), &grpc)
+			if c.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expectedErr)
+			}
+		})
+	}
+}
+
 func TestDefaultSettingsHoldsDefaultValues(t *testing.T) {
 	type TestStruct struct {
 		Key1     string          `json:"key1"`
@@ -408,6 +602,45 @@ func TestHTTPSettingsHoldsDefaultValues(t *testing.T) {
 	}
 }
 
+func TestHTTPSettingsCaCertPem(t *testing.T) {
+	pemJSON, err := json.Marshal(testCA)
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		data     string
+		expError string
+	}{
+		"CA cert PEM": {
+			data: fmt.Sprintf(`{"caCertPem": %s}`, pemJSON),
+		},
+		"CA cert PEM without HTTPS": {
+			data:     fmt.Sprintf(`{"https": false, "caCertPem": %s}`, pemJSON),
+			expError: "consulServers.http.caCertPem requires HTTPS to be enabled",
+		},
+		"CA cert PEM and file": {
+			data:     fmt.Sprintf(`{"caCertFile": "cert.pem", "caCertPem": %s}`, pemJSON),
+			expError: "consulServers.http.caCertFile and consulServers.http.caCertPem are mutually exclusive",
+		},
+		"invalid CA cert PEM": {
+			data:     `{"caCertPem": "not-a-cert"}`,
+			expError: "consulServers.http.caCertPem must contain a PEM encoded certificate",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var settings HTTPSettings
+			err := json.Unmarshal([]byte(c.data), &settings)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testCA, settings.CaCertPem)
+		})
+	}
+}
+
 func TestGRPCSettingsHoldsDefaultValues(t *testing.T) {
 	type TestStruct struct {
 		Key1 string       `json:"key1"`
@@ -535,6 +768,489 @@ func TestControllerHoldsDefaultValues(t *testing.T) {
 	}
 }
 
+func TestDuration(t *testing.T) {
+	type TestStruct struct {
+		Timeout Duration `json:"timeout,omitempty"`
+	}
+
+	cases := map[string]struct {
+		data        string
+		expDuration Duration
+		expError    string
+	}{
+		"absent": {
+			data: `{}`,
+		},
+		"null": {
+			data: `{"timeout": null}`,
+		},
+		"valid duration": {
+			data:        `{"timeout": "2m30s"}`,
+			expDuration: Duration(150 * time.Second),
+		},
+		"invalid duration": {
+			data:     `{"timeout": "soon"}`,
+			expError: `invalid duration "soon"`,
+		},
+		"non-string duration": {
+			data:     `{"timeout": 30}`,
+			expError: "cannot unmarshal number",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var unmarshalledCfg TestStruct
+			err := json.Unmarshal([]byte(c.data), &unmarshalledCfg)
+			if c.expError != "" {
+				require.ErrorContains(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expDuration, unmarshalledCfg.Timeout)
+
+			// Ensure the duration survives a round trip.
+			data, err := json.Marshal(unmarshalledCfg)
+			require.NoError(t, err)
+
+			var roundTripped TestStruct
+			require.NoError(t, json.Unmarshal(data, &roundTripped))
+			require.Equal(t, unmarshalledCfg, roundTripped)
+		})
+	}
+}
+
+func TestMeshHealthSyncInterval(t *testing.T) {
+	cases := map[string]struct {
+		data        string
+		expInterval time.Duration
+		expError    string
+	}{
+		"defaults when absent": {
+			data:        `{}`,
+			expInterval: defaultHealthSyncInterval,
+		},
+		"defaults when null": {
+			data:        `{"healthSyncInterval": null}`,
+			expInterval: defaultHealthSyncInterval,
+		},
+		"minimum interval": {
+			data:        `{"healthSyncInterval": "1s"}`,
+			expInterval: 1 * time.Second,
+		},
+		"custom interval": {
+			data:        `{"healthSyncInterval": "1m"}`,
+			expInterval: 1 * time.Minute,
+		},
+		"below minimum interval": {
+			data:     `{"healthSyncInterval": "500ms"}`,
+			expError: "mesh.healthSyncInterval must be at least 1s",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var mesh Mesh
+			err := json.Unmarshal([]byte(c.data), &mesh)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expInterval, mesh.GetHealthSyncInterval())
+		})
+	}
+}
+
+func TestMeshRetry(t *testing.T) {
+	cases := map[string]struct {
+		data     string
+		expRetry *Retry
+		expError string
+	}{
+		"unset": {
+			data: `{}`,
+		},
+		"all parameters": {
+			data: `{"retry": {"initialInterval": "500ms", "maxInterval": "30s", "multiplier": 2}}`,
+			expRetry: &Retry{
+				InitialInterval: Duration(500 * time.Millisecond),
+				MaxInterval:     Duration(30 * time.Second),
+				Multiplier:      2,
+			},
+		},
+		"negative initial interval": {
+			data:     `{"retry": {"initialInterval": "-1s"}}`,
+			expError: "mesh.retry.initialInterval must not be negative",
+		},
+		"negative max interval": {
+			data:     `{"retry": {"maxInterval": "-1s"}}`,
+			expError: "mesh.retry.maxInterval must not be negative",
+		},
+		"max interval below initial interval": {
+			data:     `{"retry": {"initialInterval": "10s", "maxInterval": "5s"}}`,
+			expError: "mesh.retry.maxInterval must be at least mesh.retry.initialInterval",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var mesh Mesh
+			err := json.Unmarshal([]byte(c.data), &mesh)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expRetry, mesh.Retry)
+			require.Equal(t, c.expRetry != nil, mesh.Retry.IsSet())
+		})
+	}
+}
+
+func TestMeshBootstrapFileMode(t *testing.T) {
+	cases := map[string]struct {
+		data     string
+		expMode  os.FileMode
+		expError string
+	}{
+		"defaults when absent": {
+			data:    `{}`,
+			expMode: 0444,
+		},
+		"group readable": {
+			data:    `{"bootstrapFileMode": "0440"}`,
+			expMode: 0440,
+		},
+		"user writable": {
+			data:    `{"bootstrapFileMode": "644"}`,
+			expMode: 0644,
+		},
+		"world-writable": {
+			data:     `{"bootstrapFileMode": "0666"}`,
+			expError: "mesh.bootstrapFileMode 0666 must not be world-writable",
+		},
+		"unreadable": {
+			data:     `{"bootstrapFileMode": "0200"}`,
+			expError: "mesh.bootstrapFileMode 0200 must be readable",
+		},
+		"not octal": {
+			data:     `{"bootstrapFileMode": "0999"}`,
+			expError: `invalid file mode "0999"`,
+		},
+		"not permission bits": {
+			data:     `{"bootstrapFileMode": "4755"}`,
+			expError: `invalid file mode "4755": only permission bits are allowed`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var mesh Mesh
+			err := json.Unmarshal([]byte(c.data), &mesh)
+			if c.expError != "" {
+				require.ErrorContains(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expMode, mesh.GetBootstrapFileMode())
+		})
+	}
+}
+
+func TestMeshNodeMeta(t *testing.T) {
+	cases := map[string]struct {
+		data     string
+		expMeta  map[string]string
+		expError string
+	}{
+		"absent": {
+			data: `{}`,
+		},
+		"valid": {
+			data:    `{"nodeMeta": {"cluster": "test", "region": "us-east-1"}}`,
+			expMeta: map[string]string{"cluster": "test", "region": "us-east-1"},
+		},
+		"synthetic node marker": {
+			data:     `{"nodeMeta": {"synthetic-node": "false"}}`,
+			expError: `mesh.nodeMeta: key "synthetic-node" is reserved`,
+		},
+		"reserved prefix": {
+			data:     `{"nodeMeta": {"consul-network-segment": "a"}}`,
+			expError: `mesh.nodeMeta: key "consul-network-segment" must not use the reserved prefix "consul-"`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var mesh Mesh
+			err := json.Unmarshal([]byte(c.data), &mesh)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expMeta, mesh.NodeMeta)
+		})
+	}
+}
+
+func TestMeshBinaryDestination(t *testing.T) {
+	cases := map[string]struct {
+		data     string
+		expPath  string
+		expError string
+	}{
+		"defaults when absent": {
+			data:    `{}`,
+			expPath: "/consul/consul-ecs",
+		},
+		"relative to the bootstrap dir": {
+			data:    `{"binaryDestination": "bin/consul-ecs"}`,
+			expPath: "/consul/bin/consul-ecs",
+		},
+		"absolute": {
+			data:    `{"binaryDestination": "/helpers/./consul-ecs"}`,
+			expPath: "/helpers/consul-ecs",
+		},
+		"directory": {
+			data:     `{"binaryDestination": "bin/"}`,
+			expError: `mesh.binaryDestination "bin/" must be a file path, not a directory`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var mesh Mesh
+			err := json.Unmarshal([]byte(c.data), &mesh)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expPath, mesh.GetBinaryDestination("/consul"))
+		})
+	}
+}
+
+func TestMeshServiceIDSuffix(t *testing.T) {
+	taskMeta := awsutil.ECSTaskMeta{TaskARN: "arn:aws:ecs:us-east-1:123456789:task/test/abcdef"}
+	otherClusterTaskMeta := awsutil.ECSTaskMeta{TaskARN: "arn:aws:ecs:us-east-1:123456789:task/other/abcdef"}
+
+	cases := map[string]struct {
+		data      string
+		expSuffix string
+		expError  string
+	}{
+		"defaults to the task ID": {
+			data:      `{}`,
+			expSuffix: "abcdef",
+		},
+		"task ID": {
+			data:      `{"serviceIdStrategy": "taskId"}`,
+			expSuffix: "abcdef",
+		},
+		"task ARN hash": {
+			data:      `{"serviceIdStrategy": "taskArnHash"}`,
+			expSuffix: "94207c17d2e46c4d",
+		},
+		"fixed suffix": {
+			data:      `{"serviceIdSuffix": "replica-1"}`,
+			expSuffix: "replica-1",
+		},
+		"strategy and suffix": {
+			data:     `{"serviceIdStrategy": "taskId", "serviceIdSuffix": "replica-1"}`,
+			expError: "mesh.serviceIdStrategy and mesh.serviceIdSuffix are mutually exclusive",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var mesh Mesh
+			err := json.Unmarshal([]byte(c.data), &mesh)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expSuffix, mesh.GetServiceIDSuffix(taskMeta))
+			// The suffix is stable across calls.
+			require.Equal(t, c.expSuffix, mesh.GetServiceIDSuffix(taskMeta))
+		})
+	}
+
+	t.Run("task ARN hash differs for the same task ID in another cluster", func(t *testing.T) {
+		mesh := Mesh{ServiceIDStrategy: ServiceIDStrategyTaskARNHash}
+		require.NotEqual(t, mesh.GetServiceIDSuffix(taskMeta), mesh.GetServiceIDSuffix(otherClusterTaskMeta))
+		require.Equal(t, (Mesh{}).GetServiceIDSuffix(taskMeta), (Mesh{}).GetServiceIDSuffix(otherClusterTaskMeta))
+	})
+}
+
+func TestServiceSocketPath(t *testing.T) {
+	cases := map[string]struct {
+		data          string
+		expSocketPath string
+		expError      string
+	}{
+		"port only": {
+			data: `{"port": 8080}`,
+		},
+		"socket path only": {
+			data:          `{"socketPath": "/shared/app.sock"}`,
+			expSocketPath: "/shared/app.sock",
+		},
+		"port and socket path": {
+			data:     `{"port": 8080, "socketPath": "/shared/app.sock"}`,
+			expError: "service.port and service.socketPath are mutually exclusive",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var service ServiceRegistration
+			err := json.Unmarshal([]byte(c.data), &service)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expSocketPath, service.ToConsulType().SocketPath)
+		})
+	}
+}
+
+func TestServiceRegistrationConnectNative(t *testing.T) {
+	service := ServiceRegistration{Name: "test-service", Port: 8080}
+	require.Nil(t, service.ToConsulType().Connect)
+
+	service.ConnectNative = true
+	require.Equal(t, &api.AgentServiceConnect{Native: true}, service.ToConsulType().Connect)
+}
+
+func TestUpstreamLocalBindAddress(t *testing.T) {
+	cases := map[string]struct {
+		data     string
+		expAddr  string
+		expError string
+	}{
+		"unset": {
+			data: `{"destinationName": "backend", "localBindPort": 1234}`,
+		},
+		"ipv4": {
+			data:    `{"destinationName": "backend", "localBindPort": 1234, "localBindAddress": "127.0.0.2"}`,
+			expAddr: "127.0.0.2",
+		},
+		"ipv6": {
+			data:    `{"destinationName": "backend", "localBindPort": 1234, "localBindAddress": "::1"}`,
+			expAddr: "::1",
+		},
+		"hostname": {
+			data:     `{"destinationName": "backend", "localBindPort": 1234, "localBindAddress": "localhost"}`,
+			expError: `proxy.upstreams: localBindAddress "localhost" of upstream "backend" must be an IP address`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var upstream Upstream
+			err := json.Unmarshal([]byte(c.data), &upstream)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expAddr, upstream.ToConsulType().LocalBindAddress)
+		})
+	}
+}
+
+func TestProxyPublicListenerBindAddress(t *testing.T) {
+	cases := map[string]struct {
+		data      string
+		expConfig map[string]interface{}
+		expError  string
+	}{
+		"unset": {
+			data:      `{"config": {"data": "some-config-data"}}`,
+			expConfig: map[string]interface{}{"data": "some-config-data"},
+		},
+		"ipv4": {
+			data:      `{"publicListenerBindAddress": "0.0.0.0"}`,
+			expConfig: map[string]interface{}{"bind_address": "0.0.0.0"},
+		},
+		"merged with the proxy config": {
+			data:      `{"publicListenerBindAddress": "::", "config": {"data": "some-config-data"}}`,
+			expConfig: map[string]interface{}{"bind_address": "::", "data": "some-config-data"},
+		},
+		"hostname": {
+			data:     `{"publicListenerBindAddress": "localhost"}`,
+			expError: `proxy.publicListenerBindAddress "localhost" must be an IP address`,
+		},
+		"also set in the proxy config": {
+			data:     `{"publicListenerBindAddress": "0.0.0.0", "config": {"bind_address": "10.0.0.1"}}`,
+			expError: "proxy.publicListenerBindAddress and proxy.config.bind_address are mutually exclusive",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var proxy AgentServiceConnectProxyConfig
+			err := json.Unmarshal([]byte(c.data), &proxy)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expConfig, proxy.ToConsulType().Config)
+			// The bind address is not added to the config from the user.
+			require.NotContains(t, proxy.Config, "bind_address")
+		})
+	}
+}
+
+func TestServiceAdditionalPorts(t *testing.T) {
+	cases := map[string]struct {
+		data     string
+		expError string
+	}{
+		"unique ports": {
+			data: `{"port": 8080, "additionalPorts": [{"name": "admin", "port": 9090}, {"name": "metrics", "port": 9091}]}`,
+		},
+		"duplicate of service port": {
+			data:     `{"port": 8080, "additionalPorts": [{"name": "admin", "port": 8080}]}`,
+			expError: "service.additionalPorts: duplicate port 8080",
+		},
+		"duplicate additional ports": {
+			data:     `{"port": 8080, "additionalPorts": [{"name": "admin", "port": 9090}, {"name": "metrics", "port": 9090}]}`,
+			expError: "service.additionalPorts: duplicate port 9090",
+		},
+		"duplicate names": {
+			data:     `{"port": 8080, "additionalPorts": [{"name": "admin", "port": 9090}, {"name": "admin", "port": 9091}]}`,
+			expError: `service.additionalPorts: duplicate name "admin"`,
+		},
+		"reserved name": {
+			data:     `{"port": 8080, "additionalPorts": [{"name": "lan", "port": 9090}]}`,
+			expError: `service.additionalPorts: name "lan" is reserved`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var service ServiceRegistration
+			err := json.Unmarshal([]byte(c.data), &service)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, map[string]api.ServiceAddress{
+				"admin":   {Address: "10.1.2.3", Port: 9090},
+				"metrics": {Address: "10.1.2.3", Port: 9091},
+			}, service.AdditionalPortAddresses("10.1.2.3"))
+		})
+	}
+}
+
 var (
 	testServiceRegistration = ServiceRegistration{
 		Name:              "service-1",
@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-multierror"
 	"github.com/xeipuuv/gojsonschema"
 )
@@ -35,6 +37,11 @@ func validate(config string) error {
 }
 
 func parse(encodedConfig string) (*Config, error) {
+	encodedConfig, err := interpolate(encodedConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := validate(encodedConfig); err != nil {
 		return nil, err
 	}
@@ -43,9 +50,162 @@ func parse(encodedConfig string) (*Config, error) {
 	if err := json.Unmarshal([]byte(encodedConfig), &config); err != nil {
 		return nil, err
 	}
+
+	if err := validateServiceOrGateway(&config); err != nil {
+		return nil, err
+	}
+
+	if config.IsGateway() && len(config.Gateway.Listeners) > 0 && config.Gateway.Kind != api.ServiceKindIngressGateway {
+		return nil, fmt.Errorf("gateway.listeners is only valid for an %s, not a %s",
+			api.ServiceKindIngressGateway, config.Gateway.Kind)
+	}
+
+	if config.IsGateway() && len(config.Gateway.LinkedServices) > 0 && config.Gateway.Kind != api.ServiceKindTerminatingGateway {
+		return nil, fmt.Errorf("gateway.linkedServices is only valid when gateway.kind is %s, not %s",
+			api.ServiceKindTerminatingGateway, config.Gateway.Kind)
+	}
+
+	if config.IsGateway() && config.Gateway.AssignPublicIP && config.Gateway.Kind != api.ServiceKindMeshGateway {
+		return nil, fmt.Errorf("gateway.assignPublicIP is only valid when gateway.kind is %s, not %s",
+			api.ServiceKindMeshGateway, config.Gateway.Kind)
+	}
+
+	if config.IsGateway() && !config.Mesh.GetSidecarEnabled() {
+		return nil, fmt.Errorf("mesh.sidecar.enabled cannot be false for a %s: gateways are registered as proxies",
+			config.Gateway.Kind)
+	}
+
+	if config.Service.ConnectNative && config.Mesh.Sidecar != nil && config.Mesh.Sidecar.Enabled != nil && *config.Mesh.Sidecar.Enabled {
+		return nil, fmt.Errorf("service.connectNative and mesh.sidecar.enabled are mutually exclusive: " +
+			"Connect-native services are registered without a sidecar proxy")
+	}
+
+	if err := validatePortConflicts(&config); err != nil {
+		return nil, err
+	}
+
+	// consul-dataplane runs the copied consul-ecs binary to look up the servers.
+	if config.ConsulServers.CloudMap != nil && !config.Mesh.GetCopyBinary() && (config.IsGateway() || config.SidecarEnabled()) {
+		return nil, fmt.Errorf("consulServers.cloudMap requires mesh.copyBinary: " +
+			"consul-dataplane runs the copied consul-ecs binary to discover the Consul servers")
+	}
+
+	for _, entry := range config.HealthSyncContainers {
+		if !isHealthSyncPattern(entry) {
+			continue
+		}
+		if _, err := path.Match(entry, ""); err != nil {
+			return nil, fmt.Errorf("healthSyncContainers: invalid pattern %q: %w", entry, err)
+		}
+	}
+
+	if err := validateHealthSyncNonEssentialContainers(&config); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
+// validateServiceOrGateway checks that the config registers either a service
+// or a gateway, but not both. A task runs a single Envoy proxy, so it can act
+// as a sidecar proxy for a service or as a gateway, never both. An absent,
+// null or empty service block does not define a service, so that configs
+// marshalled from a Config, which always include the service block, are valid.
+func validateServiceOrGateway(config *Config) error {
+	if !config.IsGateway() {
+		return nil
+	}
+	isSet, err := config.Service.isSet()
+	if err != nil {
+		return err
+	}
+	if isSet {
+		return fmt.Errorf("service and gateway are mutually exclusive: configure either a service or a %s, not both",
+			config.Gateway.Kind)
+	}
+	return nil
+}
+
+// validatePortConflicts checks that the upstream listeners of the sidecar
+// proxy do not use the same port as the application or the other proxy
+// listeners. The containers of a task share a network namespace, so a
+// conflict makes Envoy fail to bind the listener.
+func validatePortConflicts(config *Config) error {
+	if config.IsGateway() || !config.SidecarEnabled() || config.Proxy == nil {
+		return nil
+	}
+
+	type portUse struct {
+		port int
+		desc string
+	}
+	uses := []portUse{
+		{config.Service.Port, "service.port"},
+		{config.Proxy.GetPublicListenerPort(), "the proxy public listener"},
+		{GetHealthCheckPort(config.Proxy.HealthCheckPort), "the proxy health check listener"},
+	}
+	for _, p := range config.Service.AdditionalPorts {
+		uses = append(uses, portUse{p.Port, fmt.Sprintf("service.additionalPorts %q", p.Name)})
+	}
+	for _, u := range config.Proxy.Upstreams {
+		uses = append(uses, portUse{u.LocalBindPort, fmt.Sprintf("the localBindPort of upstream %q", u.DestinationName)})
+	}
+
+	used := make(map[int]string)
+	for _, use := range uses {
+		if use.port == 0 {
+			continue
+		}
+		if desc, ok := used[use.port]; ok {
+			return fmt.Errorf("port %d is used by both %s and %s", use.port, desc, use.desc)
+		}
+		used[use.port] = use.desc
+	}
+	return nil
+}
+
+// validateHealthSyncNonEssentialContainers checks that the
+// healthSyncNonEssentialContainers are not also synced through
+// healthSyncContainers, and do not include the consul-dataplane container,
+// which has its own readiness check.
+func validateHealthSyncNonEssentialContainers(config *Config) error {
+	for _, name := range config.HealthSyncNonEssentialContainers {
+		if name == ConsulDataplaneContainerName {
+			return fmt.Errorf("healthSyncNonEssentialContainers: the %s container cannot be synced", ConsulDataplaneContainerName)
+		}
+		for _, entry := range config.HealthSyncContainers {
+			if entry == name {
+				return fmt.Errorf("healthSyncNonEssentialContainers: %q is also listed in healthSyncContainers", name)
+			}
+		}
+	}
+	return nil
+}
+
+// Warnings returns problems with the config that do not prevent it from
+// being used, but likely indicate a mistake.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if !c.IsGateway() && c.Service.Port == 0 && c.Service.SocketPath == "" && c.Proxy != nil && len(c.Proxy.Upstreams) > 0 {
+		warnings = append(warnings, "service.port is not set but proxy.upstreams are defined: "+
+			"the sidecar proxy cannot route inbound traffic to the application")
+	}
+	if !c.IsGateway() && c.Proxy != nil && c.Proxy.TransparentProxy != nil && len(c.Proxy.Upstreams) > 0 {
+		warnings = append(warnings, "proxy.upstreams are defined with proxy.transparentProxy: "+
+			"explicit upstreams are not needed in transparent proxy mode and may be redundant or conflict with transparently routed upstreams")
+	}
+	for _, name := range c.HealthSyncNonEssentialContainers {
+		warnings = append(warnings, fmt.Sprintf("healthSyncNonEssentialContainers: %q is not essential: "+
+			"the task keeps running when the container exits, so its Consul check stays critical until the task is replaced", name))
+	}
+	if !c.IsGateway() {
+		for _, key := range c.Service.MissingSubsetTags() {
+			warnings = append(warnings, fmt.Sprintf("service.expectedSubsets: no service tag sets %q: "+
+				"service-resolver subsets that filter on it will not select this task", key))
+		}
+	}
+	return warnings
+}
+
 func FromEnv() (*Config, error) {
 	rawConfig := os.Getenv(ConfigEnvironmentVariable)
 	if rawConfig == "" {
@@ -53,3 +213,22 @@ func FromEnv() (*Config, error) {
 	}
 	return parse(rawConfig)
 }
+
+// FromFile reads and validates the config from the file at path.
+func FromFile(path string) (*Config, error) {
+	rawConfig, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(string(rawConfig))
+}
+
+// Load reads and validates the config for the config subcommands. The config
+// is read from the file at path, or from CONSUL_ECS_CONFIG_JSON if path is
+// empty.
+func Load(path string) (*Config, error) {
+	if path != "" {
+		return FromFile(path)
+	}
+	return FromEnv()
+}
@@ -4,9 +4,11 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul-ecs/testutil"
 	"github.com/hashicorp/consul/api"
@@ -39,6 +41,22 @@ func TestParse(t *testing.T) {
 			filename:       "resources/test_config_empty_fields.json",
 			expectedConfig: expectedConfigEmptyFields,
 		},
+		"extensive_config_gateway": {
+			filename:       "resources/test_extensive_config_gateway.json",
+			expectedConfig: withGateway(expectedExtensiveConfig, expectedExtensiveGateway),
+		},
+		"null_top_level_fields_gateway": {
+			filename:       "resources/test_config_null_top_level_fields_gateway.json",
+			expectedConfig: withGateway(expectedConfigNullTopLevelFields, expectedGatewayNullTopLevelFields),
+		},
+		"null_nested_fields_gateway": {
+			filename:       "resources/test_config_null_nested_fields_gateway.json",
+			expectedConfig: withGateway(expectedConfigNullNestedFields, expectedGatewayNullNestedFields),
+		},
+		"empty_fields_gateway": {
+			filename:       "resources/test_config_empty_fields_gateway.json",
+			expectedConfig: withGateway(expectedConfigEmptyFields, expectedGatewayEmptyFields),
+		},
 	}
 
 	for name, c := range cases {
@@ -51,6 +69,15 @@ func TestParse(t *testing.T) {
 	}
 }
 
+// withGateway returns a copy of the expected config that registers the
+// gateway instead of a service.
+func withGateway(c *Config, gateway *GatewayRegistration) *Config {
+	result := *c
+	result.Service = ServiceRegistration{}
+	result.Gateway = gateway
+	return &result
+}
+
 func TestMetaSchemaValidation(t *testing.T) {
 	// Validate that our schema adheres to the JSON Schema spec.
 	// gojsonschema embeds the meta-schema document, so no HTTP requests needed.
@@ -72,6 +99,13 @@ func TestParseErrors(t *testing.T) {
 				"consulServers is required",
 			},
 		},
+		"missing_consul_server_hosts": {
+			filename: "resources/test_config_missing_consul_server_hosts.json",
+			expectedErrors: []string{
+				"consulServers: Must validate at least one schema (anyOf)",
+				"consulServers: hosts is required",
+			},
+		},
 		"uppercase_service_names": {
 			filename: "resources/test_config_uppercase_service_names.json",
 			expectedErrors: []string{
@@ -79,6 +113,81 @@ func TestParseErrors(t *testing.T) {
 				"service.name: Does not match pattern",
 			},
 		},
+		"empty_datacenter": {
+			filename: "resources/test_config_empty_datacenter.json",
+			expectedErrors: []string{
+				"consulServers.datacenter: String length must be greater than or equal to 1",
+			},
+		},
+		"invalid_node_name": {
+			filename: "resources/test_config_invalid_node_name.json",
+			expectedErrors: []string{
+				"mesh.nodeName: Does not match pattern",
+			},
+		},
+		"invalid_node_meta": {
+			filename: "resources/test_config_invalid_node_meta.json",
+			expectedErrors: []string{
+				"mesh.nodeMeta: Property name of \"cluster:name\" does not match",
+				"mesh.nodeMeta: Does not match pattern",
+				"mesh.nodeMeta.region: Invalid type",
+			},
+		},
+		"negative_service_weights": {
+			filename: "resources/test_config_negative_service_weights.json",
+			expectedErrors: []string{
+				"service.weights.passing: Must be greater than or equal to 0",
+				"service.weights.warning: Must be greater than or equal to 0",
+			},
+		},
+		"transparent_proxy_without_outbound_listener_port": {
+			filename: "resources/test_config_transparent_proxy_zero_port.json",
+			expectedErrors: []string{
+				"proxy.transparentProxy.outboundListenerPort: Must be greater than or equal to 1",
+			},
+		},
+		"proxy_config_not_an_object": {
+			filename: "resources/test_config_proxy_config_array.json",
+			expectedErrors: []string{
+				"proxy.config: Invalid type. Expected: [object,null], given: array",
+			},
+		},
+		"invalid_upstream_mesh_gateway_mode": {
+			filename: "resources/test_config_invalid_mesh_gateway_mode.json",
+			expectedErrors: []string{
+				"proxy.upstreams.0.meshGateway.mode: proxy.upstreams.0.meshGateway.mode must be one of the following",
+			},
+		},
+		"invalid_upstream_destination_type": {
+			filename: "resources/test_config_invalid_upstream_destination_type.json",
+			expectedErrors: []string{
+				"proxy.upstreams.0.destinationType: proxy.upstreams.0.destinationType must be one of the following",
+			},
+		},
+		"invalid_ingress_listener_protocol": {
+			filename: "resources/test_config_invalid_ingress_listener_protocol.json",
+			expectedErrors: []string{
+				"gateway.listeners.0.protocol: gateway.listeners.0.protocol must be one of the following",
+			},
+		},
+		"linked_service_sni_without_ca": {
+			filename: "resources/test_config_linked_service_sni_without_ca.json",
+			expectedErrors: []string{
+				"gateway.linkedServices.0: Has a dependency on caFile",
+			},
+		},
+		"empty_gateway_kind": {
+			filename: "resources/test_config_empty_gateway_kind.json",
+			expectedErrors: []string{
+				"gateway.kind: gateway.kind must be one of the following",
+			},
+		},
+		"unknown_gateway_kind": {
+			filename: "resources/test_config_unknown_gateway_kind.json",
+			expectedErrors: []string{
+				"gateway.kind: gateway.kind must be one of the following",
+			},
+		},
 		"service_with_additional_properties": {
 			filename: "resources/test_config_additional_properties_service.json",
 			expectedErrors: []string{
@@ -106,6 +215,334 @@ func TestParseErrors(t *testing.T) {
 	}
 }
 
+func TestParseServiceAndGateway(t *testing.T) {
+	_, err := parse(OpenFile(t, "resources/test_config_service_and_gateway.json"))
+	require.EqualError(t, err, "service and gateway are mutually exclusive: configure either a service or a mesh-gateway, not both")
+}
+
+func TestParseGatewayWithoutService(t *testing.T) {
+	cases := map[string]string{
+		"null service":        `"service": null`,
+		"zero-value service":  `"service": {"name": "", "port": 0, "tags": []}`,
+		"service not present": `"logLevel": "INFO"`,
+	}
+	for name, service := range cases {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, ` +
+				`"gateway": {"kind": "mesh-gateway", "name": "ecs-mesh-gateway"}, ` + service + `}`)
+			require.NoError(t, err)
+			require.True(t, parsed.IsGateway())
+		})
+	}
+}
+
+// TestParseMarshalledGateway checks that a gateway config marshalled from a
+// Config, which always includes the service block, can be parsed back.
+func TestParseMarshalledGateway(t *testing.T) {
+	cfg := &Config{
+		BootstrapDir:  "/consul",
+		ConsulServers: ConsulServers{Hosts: "consul.dc1"},
+		Gateway: &GatewayRegistration{
+			Kind: api.ServiceKindMeshGateway,
+			Name: "ecs-mesh-gateway",
+		},
+	}
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"service":`)
+
+	parsed, err := parse(string(data))
+	require.NoError(t, err)
+	require.Equal(t, cfg.Gateway.Kind, parsed.Gateway.Kind)
+	require.Equal(t, cfg.Gateway.Name, parsed.Gateway.Name)
+}
+
+func TestParseSidecar(t *testing.T) {
+	cases := map[string]struct {
+		config     string
+		expEnabled bool
+		expError   string
+	}{
+		"service with the default": {
+			config:     `"service": {"port": 8080}`,
+			expEnabled: true,
+		},
+		"service with the sidecar disabled": {
+			config: `"service": {"port": 8080}, "mesh": {"sidecar": {"enabled": false}}`,
+		},
+		"gateway with the sidecar enabled": {
+			config:     `"gateway": {"kind": "mesh-gateway"}, "mesh": {"sidecar": {"enabled": true}}`,
+			expEnabled: true,
+		},
+		"gateway with the sidecar disabled": {
+			config:   `"gateway": {"kind": "mesh-gateway"}, "mesh": {"sidecar": {"enabled": false}}`,
+			expError: "mesh.sidecar.enabled cannot be false for a mesh-gateway: gateways are registered as proxies",
+		},
+		"connect native service": {
+			config: `"service": {"port": 8080, "connectNative": true}`,
+		},
+		"connect native service with the sidecar disabled": {
+			config: `"service": {"port": 8080, "connectNative": true}, "mesh": {"sidecar": {"enabled": false}}`,
+		},
+		"connect native service with the sidecar enabled": {
+			config: `"service": {"port": 8080, "connectNative": true}, "mesh": {"sidecar": {"enabled": true}}`,
+			expError: "service.connectNative and mesh.sidecar.enabled are mutually exclusive: " +
+				"Connect-native services are registered without a sidecar proxy",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			parsedConfig, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, ` + c.config + `}`)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expEnabled, parsedConfig.SidecarEnabled())
+		})
+	}
+}
+
+func TestParsePortConflicts(t *testing.T) {
+	cases := map[string]struct {
+		config   string
+		expError string
+	}{
+		"no conflicts": {
+			config: `"service": {"port": 8080, "additionalPorts": [{"name": "admin", "port": 9090}]},
+				"proxy": {"upstreams": [
+					{"destinationName": "backend", "localBindPort": 1234},
+					{"destinationName": "db", "localBindPort": 5432}
+				]}`,
+		},
+		"upstream and service port": {
+			config: `"service": {"port": 8080},
+				"proxy": {"upstreams": [{"destinationName": "backend", "localBindPort": 8080}]}`,
+			expError: `port 8080 is used by both service.port and the localBindPort of upstream "backend"`,
+		},
+		"upstream and additional port": {
+			config: `"service": {"port": 8080, "additionalPorts": [{"name": "admin", "port": 9090}]},
+				"proxy": {"upstreams": [{"destinationName": "backend", "localBindPort": 9090}]}`,
+			expError: `port 9090 is used by both service.additionalPorts "admin" and the localBindPort of upstream "backend"`,
+		},
+		"upstream and default public listener port": {
+			config: `"service": {"port": 8080},
+				"proxy": {"upstreams": [{"destinationName": "backend", "localBindPort": 20000}]}`,
+			expError: `port 20000 is used by both the proxy public listener and the localBindPort of upstream "backend"`,
+		},
+		"upstream and custom public listener port": {
+			config: `"service": {"port": 8080},
+				"proxy": {"publicListenerPort": 21000, "upstreams": [{"destinationName": "backend", "localBindPort": 21000}]}`,
+			expError: `port 21000 is used by both the proxy public listener and the localBindPort of upstream "backend"`,
+		},
+		"upstream and health check port": {
+			config: `"service": {"port": 8080},
+				"proxy": {"upstreams": [{"destinationName": "backend", "localBindPort": 22000}]}`,
+			expError: `port 22000 is used by both the proxy health check listener and the localBindPort of upstream "backend"`,
+		},
+		"two upstreams": {
+			config: `"service": {"port": 8080},
+				"proxy": {"upstreams": [
+					{"destinationName": "backend", "localBindPort": 1234},
+					{"destinationName": "db", "localBindPort": 1234}
+				]}`,
+			expError: `port 1234 is used by both the localBindPort of upstream "backend" and the localBindPort of upstream "db"`,
+		},
+		"service and public listener port": {
+			config:   `"service": {"port": 20000}, "proxy": {}`,
+			expError: "port 20000 is used by both service.port and the proxy public listener",
+		},
+		"ignored without a sidecar": {
+			config: `"service": {"port": 8080}, "mesh": {"sidecar": {"enabled": false}},
+				"proxy": {"upstreams": [{"destinationName": "backend", "localBindPort": 8080}]}`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, ` + c.config + `}`)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseCloudMapCopyBinary(t *testing.T) {
+	cases := map[string]struct {
+		config string
+		expErr string
+	}{
+		"copied binary": {
+			config: `"mesh": {"copyBinary": true}`,
+		},
+		"binary not copied": {
+			config: `"mesh": {"copyBinary": false}`,
+			expErr: "consulServers.cloudMap requires mesh.copyBinary: consul-dataplane runs the copied consul-ecs binary to discover the Consul servers",
+		},
+		"binary not copied without a dataplane": {
+			config: `"mesh": {"copyBinary": false, "sidecar": {"enabled": false}}`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"cloudMap": {"namespace": "consul.local", "service": "consul-server"}}, ` + c.config + `}`)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseGatewayListeners(t *testing.T) {
+	cases := map[string]struct {
+		kind   string
+		expErr string
+	}{
+		"ingress gateway": {
+			kind: "ingress-gateway",
+		},
+		"mesh gateway": {
+			kind:   "mesh-gateway",
+			expErr: "gateway.listeners is only valid for an ingress-gateway, not a mesh-gateway",
+		},
+		"terminating gateway": {
+			kind:   "terminating-gateway",
+			expErr: "gateway.listeners is only valid for an ingress-gateway, not a terminating-gateway",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, "gateway": {"kind": "` + c.kind +
+				`", "listeners": [{"port": 8080, "services": [{"name": "web"}]}]}}`)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseGatewayAssignPublicIP(t *testing.T) {
+	cases := map[string]struct {
+		kind   string
+		expErr string
+	}{
+		"mesh gateway": {
+			kind: "mesh-gateway",
+		},
+		"ingress gateway": {
+			kind:   "ingress-gateway",
+			expErr: "gateway.assignPublicIP is only valid when gateway.kind is mesh-gateway, not ingress-gateway",
+		},
+		"terminating gateway": {
+			kind:   "terminating-gateway",
+			expErr: "gateway.assignPublicIP is only valid when gateway.kind is mesh-gateway, not terminating-gateway",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, "gateway": {"kind": "` + c.kind +
+				`", "assignPublicIP": true}}`)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseGatewayLinkedServices(t *testing.T) {
+	cases := map[string]struct {
+		kind   string
+		expErr string
+	}{
+		"terminating gateway": {
+			kind: "terminating-gateway",
+		},
+		"mesh gateway": {
+			kind:   "mesh-gateway",
+			expErr: "gateway.linkedServices is only valid when gateway.kind is terminating-gateway, not mesh-gateway",
+		},
+		"ingress gateway": {
+			kind:   "ingress-gateway",
+			expErr: "gateway.linkedServices is only valid when gateway.kind is terminating-gateway, not ingress-gateway",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, "gateway": {"kind": "` + c.kind +
+				`", "linkedServices": [{"name": "billing"}]}}`)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load("resources/test_config.json")
+	require.NoError(t, err)
+	require.Equal(t, expectedConfig, cfg)
+
+	_, err = Load("resources/test_config_invalid_mesh_gateway_mode.json")
+	require.ErrorContains(t, err, "proxy.upstreams.0.meshGateway.mode: ")
+
+	t.Setenv(ConfigEnvironmentVariable, OpenFile(t, "resources/test_config.json"))
+	cfg, err = Load("")
+	require.NoError(t, err)
+	require.Equal(t, expectedConfig, cfg)
+
+	t.Setenv(ConfigEnvironmentVariable, "")
+	_, err = Load("")
+	require.EqualError(t, err, `"CONSUL_ECS_CONFIG_JSON" isn't populated`)
+}
+
+func TestParseHealthSyncContainerPatterns(t *testing.T) {
+	parsedConfig, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, "healthSyncContainers": ["app", "app-*"]}`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"app", "app-*"}, parsedConfig.HealthSyncContainers)
+
+	_, err = parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, "healthSyncContainers": ["app-["]}`)
+	require.EqualError(t, err, `healthSyncContainers: invalid pattern "app-[": syntax error in pattern`)
+}
+
+func TestParseHealthSyncNonEssentialContainers(t *testing.T) {
+	cases := map[string]struct {
+		config      string
+		expectedErr string
+	}{
+		"non-essential containers": {
+			config: `"healthSyncContainers": ["app"], "healthSyncNonEssentialContainers": ["log-router"]`,
+		},
+		"consul-dataplane": {
+			config:      `"healthSyncNonEssentialContainers": ["consul-dataplane"]`,
+			expectedErr: "healthSyncNonEssentialContainers: the consul-dataplane container cannot be synced",
+		},
+		"also in healthSyncContainers": {
+			config:      `"healthSyncContainers": ["app", "log-router"], "healthSyncNonEssentialContainers": ["log-router"]`,
+			expectedErr: `healthSyncNonEssentialContainers: "log-router" is also listed in healthSyncContainers`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parse(`{"bootstrapDir": "/consul", "consulServers": {"hosts": "consul.dc1"}, ` + c.config + `}`)
+			if c.expectedErr != "" {
+				require.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
 func TestFromEnv(t *testing.T) {
 	rawConfig := OpenFile(t, "resources/test_config.json")
 	err := os.Setenv(ConfigEnvironmentVariable, rawConfig)
@@ -120,6 +557,16 @@ func TestFromEnv(t *testing.T) {
 	require.Equal(t, expectedConfig, parsedConfig)
 }
 
+func TestFromFile(t *testing.T) {
+	parsedConfig, err := FromFile("resources/test_config.json")
+	require.NoError(t, err)
+	require.Equal(t, expectedConfig, parsedConfig)
+
+	_, err = FromFile("resources/does_not_exist.json")
+	require.Error(t, err)
+	require.True(t, os.IsNotExist(err))
+}
+
 func OpenFile(t *testing.T, path string) string {
 	byteFile, err := os.ReadFile(path)
 	require.NoError(t, err)
@@ -188,17 +635,46 @@ var (
 	}
 
 	expectedExtensiveConfig = &Config{
-		BootstrapDir:         "/consul/",
-		HealthSyncContainers: []string{"frontend"},
-		LogLevel:             "DEBUG",
+		BootstrapDir:                     "/consul/",
+		HealthSyncContainers:             []string{"frontend"},
+		HealthSyncNonEssentialContainers: []string{"log-router"},
+		LogLevel:                         "DEBUG",
+		LogFormat:                        "json",
 		Controller: Controller{
-			PartitionsEnabled: true,
-			Partition:         "default",
-			IAMRolePath:       "/consul-iam/",
+			PartitionsEnabled:        true,
+			Partition:                "default",
+			IAMRolePath:              "/consul-iam/",
+			DescribeTasksConcurrency: 8,
+			RequiredTags:             map[string]string{"environment": "test"},
+		},
+		Mesh: Mesh{
+			BootstrapTimeout:         Duration(2 * time.Minute),
+			DeregisterStaleInstances: true,
+			NodeName:                 "ecs-node-1",
+			HealthSyncInterval:       Duration(15 * time.Second),
+			BootstrapFileMode:        FileMode(0440),
+			NodeMeta:                 map[string]string{"cluster": "test"},
+			CopyBinary:               testutil.BoolPtr(false),
+			BinaryDestination:        "bin/consul-ecs",
+			CreatePartition:          true,
+			CreateNamespace:          true,
+			WriteProxyDefaults: &ProxyDefaults{
+				MeshGateway: MeshGatewayConfig{Mode: api.MeshGatewayModeLocal},
+			},
+			ServiceIDStrategy: ServiceIDStrategyTaskARNHash,
+			Sidecar:           &Sidecar{Enabled: testutil.BoolPtr(true)},
+			RollbackOnFailure: testutil.BoolPtr(false),
+			Retry: &Retry{
+				InitialInterval: Duration(2 * time.Second),
+				MaxInterval:     Duration(30 * time.Second),
+				Multiplier:      2,
+			},
 		},
 		ConsulServers: ConsulServers{
 			Hosts:           "consul.dc1",
 			SkipServerWatch: true,
+			HTTPTimeout:     Duration(10 * time.Second),
+			Datacenter:      "dc1",
 			Defaults: DefaultSettings{
 				CaCertFile:    "/consul/ca-cert.pem",
 				TLSServerName: "consul.dc1",
@@ -246,39 +722,21 @@ var (
 			},
 			Namespace: "test-ns",
 			Partition: "test-partition",
-		},
-		Gateway: &GatewayRegistration{
-			Kind: "mesh-gateway",
-			LanAddress: &GatewayAddress{
-				Address: "10.0.0.1",
-				Port:    8443,
-			},
-			WanAddress: &GatewayAddress{
-				Address: "172.16.0.0",
-				Port:    443,
-			},
-			Name: "ecs-mesh-gateway",
-			Tags: []string{"a", "b"},
-			Meta: map[string]string{
-				"env":     "test",
-				"version": "x.y.z",
-			},
-			Namespace:       "ns1",
-			Partition:       "ptn1",
-			HealthCheckPort: 22000,
-			Proxy: &GatewayProxyConfig{
-				Config: map[string]interface{}{
-					"data": "some-config-data",
-				},
+			AdditionalPorts: []ServicePort{
+				{Name: "admin", Port: 9090},
 			},
+			SourceTag:       "ecs-pipeline",
+			AddLocalityTags: true,
+			ExpectedSubsets: []string{"frontend"},
 		},
 		Proxy: &AgentServiceConnectProxyConfig{
 			Config: map[string]interface{}{
 				"data": "some-config-data",
 			},
-			PublicListenerPort:  21000,
-			HealthCheckPort:     22000,
-			LocalServiceAddress: "10.10.10.10",
+			PublicListenerPort:        21000,
+			PublicListenerBindAddress: "0.0.0.0",
+			HealthCheckPort:           22000,
+			LocalServiceAddress:       "10.10.10.10",
 			Upstreams: []Upstream{
 				{
 					DestinationType:      api.UpstreamDestTypeService,
@@ -287,7 +745,7 @@ var (
 					DestinationPeer:      "test-peer",
 					DestinationName:      "backend",
 					Datacenter:           "dc2",
-					LocalBindAddress:     "localhost",
+					LocalBindAddress:     "127.0.0.1",
 					LocalBindPort:        1234,
 					Config: map[string]interface{}{
 						"data": "some-upstream-config-data",
@@ -311,6 +769,38 @@ var (
 					},
 				},
 			},
+			TransparentProxy: &TransparentProxyConfig{
+				OutboundListenerPort: 15001,
+				DialedDirectly:       true,
+			},
+		},
+	}
+
+	expectedExtensiveGateway = &GatewayRegistration{
+		Kind: "mesh-gateway",
+		LanAddress: &GatewayAddress{
+			Address: "10.0.0.1",
+			Port:    8443,
+		},
+		WanAddress: &GatewayAddress{
+			Address: "172.16.0.0",
+			Port:    443,
+		},
+		Name: "ecs-mesh-gateway",
+		Tags: []string{"a", "b"},
+		Meta: map[string]string{
+			"env":     "test",
+			"version": "x.y.z",
+		},
+		Namespace:       "ns1",
+		Partition:       "ptn1",
+		HealthCheckPort: 22000,
+		AssignPublicIP:  true,
+		SourceTag:       "ecs-pipeline",
+		Proxy: &GatewayProxyConfig{
+			Config: map[string]interface{}{
+				"data": "some-config-data",
+			},
 		},
 	}
 
@@ -354,18 +844,6 @@ var (
 			STSEndpoint:         "",
 			ServerIDHeaderValue: "",
 		},
-		Gateway: &GatewayRegistration{
-			Kind:            "mesh-gateway",
-			LanAddress:      nil,
-			WanAddress:      nil,
-			Name:            "",
-			Tags:            nil,
-			Meta:            nil,
-			Namespace:       "",
-			Partition:       "",
-			Proxy:           nil,
-			HealthCheckPort: 0,
-		},
 		Service: ServiceRegistration{
 			Name:              "",
 			Tags:              nil,
@@ -379,6 +857,19 @@ var (
 		Proxy: nil,
 	}
 
+	expectedGatewayNullTopLevelFields = &GatewayRegistration{
+		Kind:            "mesh-gateway",
+		LanAddress:      nil,
+		WanAddress:      nil,
+		Name:            "",
+		Tags:            nil,
+		Meta:            nil,
+		Namespace:       "",
+		Partition:       "",
+		Proxy:           nil,
+		HealthCheckPort: 0,
+	}
+
 	expectedConfigNullNestedFields = &Config{
 		BootstrapDir:         "/consul/",
 		HealthSyncContainers: nil,
@@ -419,26 +910,6 @@ var (
 				EnableTLS:     nil,
 			},
 		},
-		Gateway: &GatewayRegistration{
-			Kind: "mesh-gateway",
-			LanAddress: &GatewayAddress{
-				Address: "",
-				Port:    0,
-			},
-			WanAddress: &GatewayAddress{
-				Address: "",
-				Port:    0,
-			},
-			Name:            "",
-			Tags:            nil,
-			Meta:            nil,
-			Namespace:       "",
-			Partition:       "",
-			HealthCheckPort: 22000,
-			Proxy: &GatewayProxyConfig{
-				Config: nil,
-			},
-		},
 		Service: ServiceRegistration{
 			Name:              "",
 			Tags:              nil,
@@ -472,6 +943,27 @@ var (
 		},
 	}
 
+	expectedGatewayNullNestedFields = &GatewayRegistration{
+		Kind: "mesh-gateway",
+		LanAddress: &GatewayAddress{
+			Address: "",
+			Port:    0,
+		},
+		WanAddress: &GatewayAddress{
+			Address: "",
+			Port:    0,
+		},
+		Name:            "",
+		Tags:            nil,
+		Meta:            nil,
+		Namespace:       "",
+		Partition:       "",
+		HealthCheckPort: 22000,
+		Proxy: &GatewayProxyConfig{
+			Config: nil,
+		},
+	}
+
 	expectedConfigEmptyFields = &Config{
 		BootstrapDir:         "/consul/",
 		HealthSyncContainers: []string{},
@@ -511,18 +1003,6 @@ var (
 				EnableTLS:     nil,
 			},
 		},
-		Gateway: &GatewayRegistration{
-			Kind:            "mesh-gateway",
-			LanAddress:      &GatewayAddress{},
-			WanAddress:      &GatewayAddress{},
-			Name:            "",
-			Tags:            []string{},
-			Meta:            map[string]string{},
-			Namespace:       "",
-			Partition:       "",
-			Proxy:           &GatewayProxyConfig{},
-			HealthCheckPort: 0,
-		},
 		Service: ServiceRegistration{
 			Name:              "",
 			Tags:              []string{},
@@ -542,4 +1022,127 @@ var (
 			HealthCheckPort:    0,
 		},
 	}
+
+	expectedGatewayEmptyFields = &GatewayRegistration{
+		Kind:            "mesh-gateway",
+		LanAddress:      &GatewayAddress{},
+		WanAddress:      &GatewayAddress{},
+		Name:            "",
+		Tags:            []string{},
+		Meta:            map[string]string{},
+		Namespace:       "",
+		Partition:       "",
+		Proxy:           &GatewayProxyConfig{},
+		HealthCheckPort: 0,
+	}
 )
+
+func TestWarnings(t *testing.T) {
+	upstreams := []Upstream{{DestinationName: "backend", LocalBindPort: 1234}}
+	cases := map[string]struct {
+		config      *Config
+		expWarnings []string
+	}{
+		"service with port and upstreams": {
+			config: &Config{
+				Service: ServiceRegistration{Port: 8080},
+				Proxy:   &AgentServiceConnectProxyConfig{Upstreams: upstreams},
+			},
+		},
+		"service with socket path and upstreams": {
+			config: &Config{
+				Service: ServiceRegistration{SocketPath: "/shared/app.sock"},
+				Proxy:   &AgentServiceConnectProxyConfig{Upstreams: upstreams},
+			},
+		},
+		"portless service without upstreams": {
+			config: &Config{
+				Proxy: &AgentServiceConnectProxyConfig{},
+			},
+		},
+		"portless service with upstreams": {
+			config: &Config{
+				Proxy: &AgentServiceConnectProxyConfig{Upstreams: upstreams},
+			},
+			expWarnings: []string{
+				"service.port is not set but proxy.upstreams are defined: the sidecar proxy cannot route inbound traffic to the application",
+			},
+		},
+		"gateway": {
+			config: &Config{
+				Gateway: &GatewayRegistration{Kind: "mesh-gateway"},
+				Proxy:   &AgentServiceConnectProxyConfig{Upstreams: upstreams},
+			},
+		},
+		"transparent proxy without upstreams": {
+			config: &Config{
+				Service: ServiceRegistration{Port: 8080},
+				Proxy: &AgentServiceConnectProxyConfig{
+					TransparentProxy: &TransparentProxyConfig{OutboundListenerPort: 15001},
+				},
+			},
+		},
+		"transparent proxy with upstreams": {
+			config: &Config{
+				Service: ServiceRegistration{Port: 8080},
+				Proxy: &AgentServiceConnectProxyConfig{
+					TransparentProxy: &TransparentProxyConfig{OutboundListenerPort: 15001},
+					Upstreams:        upstreams,
+				},
+			},
+			expWarnings: []string{
+				"proxy.upstreams are defined with proxy.transparentProxy: explicit upstreams are not needed " +
+					"in transparent proxy mode and may be redundant or conflict with transparently routed upstreams",
+			},
+		},
+		"gateway with transparent proxy and upstreams": {
+			config: &Config{
+				Gateway: &GatewayRegistration{Kind: "mesh-gateway"},
+				Proxy: &AgentServiceConnectProxyConfig{
+					TransparentProxy: &TransparentProxyConfig{OutboundListenerPort: 15001},
+					Upstreams:        upstreams,
+				},
+			},
+		},
+		"matching subset tags": {
+			config: &Config{
+				Service: ServiceRegistration{
+					Port:            8080,
+					Tags:            []string{"version:v2", "canary"},
+					ExpectedSubsets: []string{"version", "canary"},
+				},
+				Proxy: &AgentServiceConnectProxyConfig{},
+			},
+		},
+		"missing subset tags": {
+			config: &Config{
+				Service: ServiceRegistration{
+					Port:            8080,
+					Tags:            []string{"versions:v2", "canary"},
+					ExpectedSubsets: []string{"version", "canary", "track"},
+				},
+				Proxy: &AgentServiceConnectProxyConfig{},
+			},
+			expWarnings: []string{
+				`service.expectedSubsets: no service tag sets "version": service-resolver subsets that filter on it will not select this task`,
+				`service.expectedSubsets: no service tag sets "track": service-resolver subsets that filter on it will not select this task`,
+			},
+		},
+		"non-essential health sync containers": {
+			config: &Config{
+				HealthSyncNonEssentialContainers: []string{"log-router"},
+				Service:                          ServiceRegistration{Port: 8080},
+				Proxy:                            &AgentServiceConnectProxyConfig{},
+			},
+			expWarnings: []string{
+				`healthSyncNonEssentialContainers: "log-router" is not essential: the task keeps running when the container exits, ` +
+					"so its Consul check stays critical until the task is replaced",
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expWarnings, c.config.Warnings())
+		})
+	}
+}
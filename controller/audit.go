@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controller
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// AuditEventTokenDeleted is recorded when the controller deletes the ACL
+	// token of a task that no longer exists.
+	AuditEventTokenDeleted = "token-deleted"
+
+	// builtinServicePolicy is the templated policy that grants a service identity.
+	builtinServicePolicy = "builtin/service"
+)
+
+// AuditEvent is an ACL token lifecycle event written to the AuditLog. It
+// identifies the token by its accessor ID and never includes the secret ID.
+type AuditEvent struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	AccessorID string    `json:"accessorId"`
+	TaskID     TaskID    `json:"taskId"`
+	Cluster    string    `json:"cluster"`
+	Services   []string  `json:"services,omitempty"`
+	Partition  string    `json:"partition,omitempty"`
+	Namespace  string    `json:"namespace,omitempty"`
+	// Actor is the controller that made the change, such as its task ARN.
+	Actor string `json:"actor"`
+}
+
+// AuditLog writes AuditEvents as JSON lines, separately from the operational
+// logs. Its methods are no-ops on a nil *AuditLog.
+type AuditLog struct {
+	mu    sync.Mutex
+	w     io.Writer
+	actor string
+	now   func() time.Time
+}
+
+// NewAuditLog returns an AuditLog that writes to w, with the actor recorded
+// in each event.
+func NewAuditLog(w io.Writer, actor string) *AuditLog {
+	return &AuditLog{w: w, actor: actor, now: time.Now}
+}
+
+// tokenDeleted records that the token of the task was deleted.
+func (a *AuditLog) tokenDeleted(t *TaskState, token *api.ACLTokenListEntry) error {
+	if a == nil {
+		return nil
+	}
+	return a.write(AuditEvent{
+		Event:      AuditEventTokenDeleted,
+		AccessorID: token.AccessorID,
+		TaskID:     t.TaskID,
+		Cluster:    t.ClusterARN,
+		Services:   tokenServices(token),
+		Partition:  token.Partition,
+		Namespace:  token.Namespace,
+	})
+}
+
+func (a *AuditLog) write(event AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	event.Time = a.now().UTC()
+	event.Actor = a.actor
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = a.w.Write(append(data, '\n'))
+	return err
+}
+
+// tokenServices returns the names of the services that the token has an
+// identity for.
+func tokenServices(token *api.ACLTokenListEntry) []string {
+	var services []string
+	for _, identity := range token.ServiceIdentities {
+		services = append(services, identity.ServiceName)
+	}
+	for _, policy := range token.TemplatedPolicies {
+		if policy.TemplateName == builtinServicePolicy && policy.TemplateVariables != nil {
+			services = append(services, policy.TemplateVariables.Name)
+		}
+	}
+	return services
+}
@@ -0,0 +1,110 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogTokenDeleted(t *testing.T) {
+	tokens := []*api.ACLTokenListEntry{
+		makeToken(t, "stopped-task", true),
+		makeToken(t, "stopped-task", true),
+	}
+	tokens[1].TemplatedPolicies = nil
+	tokens[1].ServiceIdentities = []*api.ACLServiceIdentity{{ServiceName: "other-service"}}
+
+	var deleted []string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodDelete, r.Method)
+		deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/v1/acl/token/"))
+		_, _ = w.Write([]byte("true"))
+	}))
+	t.Cleanup(consulServer.Close)
+	consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	now := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	auditLog := NewAuditLog(&buf, "arn:aws:ecs:bogus-east-1:000000000000:task/my-cluster/controller-task")
+	auditLog.now = func() time.Time { return now }
+
+	state := &TaskState{
+		TaskID:     "stopped-task",
+		ClusterARN: testClusterArn,
+		ACLTokens:  tokens,
+		Log:        hclog.NewNullLogger(),
+		AuditLog:   auditLog,
+	}
+	require.NoError(t, state.Delete(consulClient))
+	require.Equal(t, []string{tokens[0].AccessorID, tokens[1].AccessorID}, deleted)
+
+	output := buf.String()
+	for _, token := range tokens {
+		require.NotContains(t, output, token.SecretID)
+	}
+
+	var events []AuditEvent
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var event AuditEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+	expected := []AuditEvent{
+		{
+			Time:       now,
+			Event:      AuditEventTokenDeleted,
+			AccessorID: tokens[0].AccessorID,
+			TaskID:     "stopped-task",
+			Cluster:    testClusterArn,
+			Services:   []string{"test-service"},
+			Actor:      "arn:aws:ecs:bogus-east-1:000000000000:task/my-cluster/controller-task",
+		},
+		{
+			Time:       now,
+			Event:      AuditEventTokenDeleted,
+			AccessorID: tokens[1].AccessorID,
+			TaskID:     "stopped-task",
+			Cluster:    testClusterArn,
+			Services:   []string{"other-service"},
+			Actor:      "arn:aws:ecs:bogus-east-1:000000000000:task/my-cluster/controller-task",
+		},
+	}
+	require.Equal(t, expected, events)
+}
+
+func TestAuditLogNotWrittenOnFailedDelete(t *testing.T) {
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(consulServer.Close)
+	consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	state := &TaskState{
+		TaskID:     "stopped-task",
+		ClusterARN: testClusterArn,
+		ACLTokens:  []*api.ACLTokenListEntry{makeToken(t, "stopped-task", true)},
+		Log:        hclog.NewNullLogger(),
+		AuditLog:   NewAuditLog(&buf, "controller"),
+	}
+	require.Error(t, state.Delete(consulClient))
+	require.Empty(t, buf.String())
+}
+
+func TestNilAuditLog(t *testing.T) {
+	var auditLog *AuditLog
+	require.NoError(t, auditLog.tokenDeleted(&TaskState{}, &api.ACLTokenListEntry{}))
+}
@@ -6,13 +6,21 @@ package controller
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-multierror"
 )
 
-const DefaultPollingInterval = 10 * time.Second
+const (
+	DefaultPollingInterval = 10 * time.Second
+
+	// pollingJitter is the maximum fraction of the PollingInterval that is
+	// randomly added to or subtracted from each interval, so that controllers
+	// in different clusters do not poll the AWS and Consul APIs in lockstep.
+	pollingJitter = 0.1
+)
 
 // Controller is a generic controller implementation.
 // It periodically polls for Resources and reconciles
@@ -21,28 +29,118 @@ type Controller struct {
 	// Resources lists resources for Controller to reconcile.
 	Resources ResourceLister
 	// PollingInterval is an interval that Controller will use to reconcile all Resources.
+	// Each interval is jittered by up to 10%.
 	PollingInterval time.Duration
 	// Log is the logger used by the Controller.
 	Log hclog.Logger
+	// Metrics records the outcome of each reconcile. Metrics are disabled if nil.
+	Metrics *Metrics
+	// NewLock creates a lock that is acquired before reconciling, so that only
+	// one of several controllers for the same cluster reconciles at a time.
+	// The others stand by until the lock is released or lost. A new lock is
+	// created for each attempt to acquire it, so that it uses the current
+	// Consul server. If nil, the Controller always reconciles.
+	NewLock func() (Locker, error)
+}
+
+// Locker is a lock that is held by at most one Controller at a time, such as
+// a Consul lock (*api.Lock).
+type Locker interface {
+	// Lock blocks until the lock is acquired or stopCh is closed, in which
+	// case it returns a nil channel. The returned channel is closed when the
+	// lock is lost.
+	Lock(stopCh <-chan struct{}) (<-chan struct{}, error)
+	// Unlock releases the lock.
+	Unlock() error
 }
 
 // Run starts the Controller loop. The loop will exit when ctx is canceled.
 func (c *Controller) Run(ctx context.Context) {
+	if c.NewLock == nil {
+		c.reconcileUntil(ctx, nil)
+		return
+	}
+
+	for {
+		lock, lostCh, err := c.acquireLock(ctx)
+		if err != nil {
+			c.Log.Error("error acquiring the controller lock", "err", err)
+			select {
+			case <-time.After(c.jitteredInterval()):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if lostCh == nil {
+			return
+		}
+		c.Log.Info("acquired the controller lock; starting to reconcile")
+
+		c.reconcileUntil(ctx, lostCh)
+
+		// Release the lock also after it is lost, to stop renewing its session.
+		if err := lock.Unlock(); err != nil {
+			c.Log.Debug("error releasing the controller lock", "err", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		c.Log.Warn("lost the controller lock; stopped reconciling")
+	}
+}
+
+// acquireLock creates a lock and blocks until it is acquired or ctx is
+// canceled, in which case the returned channel is nil.
+func (c *Controller) acquireLock(ctx context.Context) (Locker, <-chan struct{}, error) {
+	lock, err := c.NewLock()
+	if err != nil {
+		return nil, nil, err
+	}
+	c.Log.Info("waiting to acquire the controller lock")
+	lostCh, err := lock.Lock(ctx.Done())
+	if err != nil {
+		return nil, nil, err
+	}
+	return lock, lostCh, nil
+}
+
+// reconcileUntil reconciles every PollingInterval until ctx is canceled or
+// lostCh is closed. A nil lostCh is never closed.
+func (c *Controller) reconcileUntil(ctx context.Context, lostCh <-chan struct{}) {
 	for {
 		select {
-		case <-time.After(c.PollingInterval):
+		case <-time.After(c.jitteredInterval()):
+			// Do not reconcile if the lock was lost while waiting.
+			select {
+			case <-lostCh:
+				return
+			default:
+			}
 			err := c.reconcile()
 			if err != nil {
 				c.Log.Error("error during reconcile", "err", err)
 			}
+		case <-lostCh:
+			return
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// jitteredInterval returns the PollingInterval with a random jitter of up to
+// pollingJitter applied.
+func (c *Controller) jitteredInterval() time.Duration {
+	jitter := (rand.Float64()*2 - 1) * pollingJitter
+	return c.PollingInterval + time.Duration(jitter*float64(c.PollingInterval))
+}
+
 // reconcile first lists all resources and then reconciles them with Controller's state.
-func (c *Controller) reconcile() error {
+func (c *Controller) reconcile() (err error) {
+	start := time.Now()
+	defer func() { c.Metrics.observeReconcile(start, err) }()
+
 	c.Log.Debug("starting reconcile")
 	resources, err := c.Resources.List()
 	if err != nil {
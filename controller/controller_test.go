@@ -52,9 +52,177 @@ func TestRun(t *testing.T) {
 	})
 }
 
+func TestRunWithLock(t *testing.T) {
+	t.Parallel()
+	server := &testLockServer{}
+	listers := []*testResourceLister{{}, {}}
+	cancels := make([]context.CancelFunc, len(listers))
+	for i, lister := range listers {
+		ctrl := Controller{
+			Resources:       lister,
+			PollingInterval: 100 * time.Millisecond,
+			Log:             hclog.NewNullLogger(),
+			NewLock: func() (Locker, error) {
+				return &testLock{server: server}, nil
+			},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		cancels[i] = cancel
+		go ctrl.Run(ctx)
+	}
+
+	// Only the controller holding the lock reconciles.
+	var leader, standby int
+	retry.Run(t, func(r *retry.R) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.NotEqual(r, listers[0].nsReconciled, listers[1].nsReconciled)
+	})
+	mutex.Lock()
+	if listers[1].nsReconciled {
+		leader, standby = 1, 0
+	} else {
+		leader, standby = 0, 1
+	}
+	mutex.Unlock()
+
+	time.Sleep(500 * time.Millisecond)
+	mutex.Lock()
+	require.False(t, listers[standby].nsReconciled)
+	require.Greater(t, listers[leader].reconciles, 1)
+	mutex.Unlock()
+
+	// The standby takes over when the leader stops and releases the lock.
+	cancels[leader]()
+	retry.Run(t, func(r *retry.R) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.True(r, listers[standby].nsReconciled)
+	})
+}
+
+func TestRunLosesLock(t *testing.T) {
+	t.Parallel()
+	server := &testLockServer{}
+	lister := &testResourceLister{}
+	ctrl := Controller{
+		Resources:       lister,
+		PollingInterval: 100 * time.Millisecond,
+		Log:             hclog.NewNullLogger(),
+		NewLock: func() (Locker, error) {
+			return &testLock{server: server}, nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go ctrl.Run(ctx)
+
+	retry.Run(t, func(r *retry.R) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.True(r, lister.nsReconciled)
+	})
+
+	// Hand the lock to another holder. The controller stops reconciling.
+	server.steal()
+	time.Sleep(200 * time.Millisecond)
+	mutex.Lock()
+	reconciles := lister.reconciles
+	mutex.Unlock()
+	time.Sleep(500 * time.Millisecond)
+	mutex.Lock()
+	require.Equal(t, reconciles, lister.reconciles)
+	mutex.Unlock()
+
+	// The controller reconciles again once it reacquires the lock.
+	server.release()
+	retry.Run(t, func(r *retry.R) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.Greater(r, lister.reconciles, reconciles)
+	})
+}
+
+func TestJitteredInterval(t *testing.T) {
+	ctrl := Controller{PollingInterval: 10 * time.Second}
+
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		interval := ctrl.jitteredInterval()
+		require.GreaterOrEqual(t, interval, 9*time.Second)
+		require.LessOrEqual(t, interval, 11*time.Second)
+		seen[interval] = true
+	}
+	require.Greater(t, len(seen), 1, "expected the interval to be jittered")
+}
+
+// testLockServer is the shared state of the testLocks, which are held by at
+// most one holder at a time.
+type testLockServer struct {
+	mu     sync.Mutex
+	holder interface{}
+	lostCh chan struct{}
+}
+
+// steal hands the lock to a holder other than the testLocks.
+func (s *testLockServer) steal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lostCh != nil {
+		close(s.lostCh)
+		s.lostCh = nil
+	}
+	s.holder = s
+}
+
+// release frees the lock from the holder set by steal.
+func (s *testLockServer) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holder == s {
+		s.holder = nil
+	}
+}
+
+type testLock struct {
+	server *testLockServer
+}
+
+func (l *testLock) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
+	for {
+		l.server.mu.Lock()
+		if l.server.holder == nil {
+			l.server.holder = l
+			l.server.lostCh = make(chan struct{})
+			lostCh := l.server.lostCh
+			l.server.mu.Unlock()
+			return lostCh, nil
+		}
+		l.server.mu.Unlock()
+
+		select {
+		case <-stopCh:
+			return nil, nil
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (l *testLock) Unlock() error {
+	l.server.mu.Lock()
+	defer l.server.mu.Unlock()
+	if l.server.holder == l {
+		l.server.holder = nil
+		l.server.lostCh = nil
+	}
+	return nil
+}
+
 type testResourceLister struct {
 	resources    []*testResource
 	nsReconciled bool
+	reconciles   int
 }
 
 type testResource struct {
@@ -78,6 +246,7 @@ func (t *testResourceLister) ReconcileNamespaces([]Resource) error {
 	defer mutex.Unlock()
 
 	t.nsReconciled = true
+	t.reconciles++
 	return nil
 }
 
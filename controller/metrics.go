@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "consul_ecs_controller"
+
+// Metrics are the Prometheus metrics for the controller's reconcile loop.
+// All methods are safe to call on a nil *Metrics, which disables metrics.
+type Metrics struct {
+	tasksListed           prometheus.Gauge
+	tokensDeleted         prometheus.Counter
+	servicesDeregistered  prometheus.Counter
+	reconcileErrors       prometheus.Counter
+	reconcileDurationSecs prometheus.Histogram
+}
+
+// NewMetrics creates the controller metrics and registers them with reg.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		tasksListed: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "tasks_listed",
+			Help:      "Number of mesh tasks managed by the controller found in the last reconcile.",
+		}),
+		tokensDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "tokens_deleted_total",
+			Help:      "Number of ACL tokens deleted for tasks that are no longer running.",
+		}),
+		servicesDeregistered: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "services_deregistered_total",
+			Help:      "Number of services deregistered for tasks that are no longer running.",
+		}),
+		reconcileErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconcile_errors_total",
+			Help:      "Number of reconciles that returned an error.",
+		}),
+		reconcileDurationSecs: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconcile_duration_seconds",
+			Help:      "Time taken to reconcile all tasks.",
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.tasksListed,
+		m.tokensDeleted,
+		m.servicesDeregistered,
+		m.reconcileErrors,
+		m.reconcileDurationSecs,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) setTasksListed(n int) {
+	if m != nil {
+		m.tasksListed.Set(float64(n))
+	}
+}
+
+func (m *Metrics) incTokensDeleted() {
+	if m != nil {
+		m.tokensDeleted.Inc()
+	}
+}
+
+func (m *Metrics) incServicesDeregistered() {
+	if m != nil {
+		m.servicesDeregistered.Inc()
+	}
+}
+
+func (m *Metrics) observeReconcile(start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.reconcileDurationSecs.Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.reconcileErrors.Inc()
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/hashicorp/consul-ecs/controller/mocks"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	tasks := []*ecs.Task{
+		makeECSTask(t, "running-task-1", meshTag, "true"),
+		makeECSTask(t, "running-task-2", meshTag, "true"),
+	}
+	tokens := []*api.ACLTokenListEntry{
+		makeToken(t, "running-task-1", true),
+		makeToken(t, "stopped-task", true),
+		makeToken(t, "stopped-task", true),
+	}
+	services := &api.CatalogNodeServiceList{
+		Services: []*api.AgentService{
+			constructSvcRegInput(testClusterArn, "svc", "stopped-task").Service,
+		},
+	}
+
+	// Fake the Consul endpoints used to list and clean up tokens and services.
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/acl/tokens":
+			require.NoError(t, json.NewEncoder(w).Encode(tokens))
+		case r.URL.Path == "/v1/catalog/node-services/"+testClusterArn:
+			require.NoError(t, json.NewEncoder(w).Encode(services))
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v1/acl/token/"):
+			_, _ = w.Write([]byte("true"))
+		case r.Method == http.MethodPut && r.URL.Path == "/v1/catalog/deregister":
+			_, _ = w.Write([]byte("true"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(consulServer.Close)
+
+	reg := prometheus.NewRegistry()
+	metrics, err := NewMetrics(reg)
+	require.NoError(t, err)
+
+	ctrl := Controller{
+		Resources: TaskStateLister{
+			ECSClient: &mocks.ECSClient{Tasks: tasks},
+			SetupConsulClientFn: func() (*api.Client, error) {
+				return api.NewClient(&api.Config{Address: consulServer.URL})
+			},
+			ClusterARN: testClusterArn,
+			Log:        hclog.NewNullLogger(),
+			Metrics:    metrics,
+		},
+		Log:     hclog.NewNullLogger(),
+		Metrics: metrics,
+	}
+	require.NoError(t, ctrl.reconcile())
+
+	metricsServer := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	t.Cleanup(metricsServer.Close)
+	resp, err := http.Get(metricsServer.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		"consul_ecs_controller_tasks_listed 2",
+		"consul_ecs_controller_tokens_deleted_total 2",
+		"consul_ecs_controller_services_deregistered_total 1",
+		"consul_ecs_controller_reconcile_errors_total 0",
+		"consul_ecs_controller_reconcile_duration_seconds_count 1",
+	} {
+		require.Contains(t, string(body), fmt.Sprintln(line))
+	}
+}
@@ -4,21 +4,68 @@
 package mocks
 
 import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
 	mapset "github.com/deckarep/golang-set"
 )
 
+// maxDescribeTasks matches the limit of the ECS DescribeTasks API.
+const maxDescribeTasks = 100
+
 type ECSClient struct {
 	ecsiface.ECSAPI
 	Tasks           []*ecs.Task
 	PaginateResults bool
+
+	// PageSize is the number of tasks returned per ListTasks page. It takes
+	// precedence over PaginateResults if set.
+	PageSize int
+
+	// DescribeTasksErr is returned by DescribeTasks if set.
+	DescribeTasksErr error
+
+	// ListTasksThrottles and DescribeTasksThrottles are the number of
+	// ListTasks and DescribeTasks calls that fail with a ThrottlingException
+	// before the calls succeed.
+	ListTasksThrottles     int
+	DescribeTasksThrottles int
+
+	mu                 sync.Mutex
+	describeTasksCalls int
 }
 
 func (m *ECSClient) ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	if m.throttle(&m.ListTasksThrottles) {
+		return nil, throttlingErr()
+	}
+
 	var taskARNs []*string
 	var nextToken *string
-	if m.PaginateResults && input.NextToken == nil {
+	if m.PageSize > 0 {
+		start := 0
+		if input.NextToken != nil {
+			var err error
+			start, err = strconv.Atoi(*input.NextToken)
+			if err != nil {
+				return nil, err
+			}
+		}
+		end := start + m.PageSize
+		if end < len(m.Tasks) {
+			nextToken = aws.String(strconv.Itoa(end))
+		} else {
+			end = len(m.Tasks)
+		}
+		for _, t := range m.Tasks[start:end] {
+			taskARNs = append(taskARNs, t.TaskArn)
+		}
+	} else if m.PaginateResults && input.NextToken == nil {
 		for _, t := range m.Tasks[:len(m.Tasks)/2] {
 			taskARNs = append(taskARNs, t.TaskArn)
 		}
@@ -39,6 +86,21 @@ func (m *ECSClient) ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput,
 }
 
 func (m *ECSClient) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	m.mu.Lock()
+	m.describeTasksCalls++
+	m.mu.Unlock()
+
+	if m.throttle(&m.DescribeTasksThrottles) {
+		return nil, throttlingErr()
+	}
+
+	if m.DescribeTasksErr != nil {
+		return nil, m.DescribeTasksErr
+	}
+	if len(input.Tasks) > maxDescribeTasks {
+		return nil, fmt.Errorf("too many tasks: %d", len(input.Tasks))
+	}
+
 	var tasksResult []*ecs.Task
 	taskARNsInput := mapset.NewSet()
 	for _, arn := range input.Tasks {
@@ -53,3 +115,26 @@ func (m *ECSClient) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeT
 	}
 	return &ecs.DescribeTasksOutput{Tasks: tasksResult}, nil
 }
+
+// throttle returns true if the call should be throttled, and decrements the
+// remaining throttled calls.
+func (m *ECSClient) throttle(remaining *int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if *remaining <= 0 {
+		return false
+	}
+	*remaining--
+	return true
+}
+
+func throttlingErr() error {
+	return awserr.New("ThrottlingException", "Rate exceeded", nil)
+}
+
+// DescribeTasksCalls returns the number of DescribeTasks calls made.
+func (m *ECSClient) DescribeTasksCalls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.describeTasksCalls
+}
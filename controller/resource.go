@@ -6,11 +6,14 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/cenkalti/backoff/v4"
 	"github.com/hashicorp/consul-ecs/awsutil"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
@@ -22,8 +25,12 @@ const (
 	meshTag = "consul.hashicorp.com/mesh"
 
 	// Included in ACL token description.
-	clusterTag = "consul.hashicorp.com/cluster"
-	taskIdTag  = "consul.hashicorp.com/task-id"
+	clusterTag    = "consul.hashicorp.com/cluster"
+	taskIdTag     = "consul.hashicorp.com/task-id"
+	taskFamilyTag = "consul.hashicorp.com/task-family"
+
+	// Included in the meta of service registrations.
+	serviceFamilyMeta = "task-family"
 
 	// Consul Enterprise support for partitions and namespaces
 	partitionTag = "consul.hashicorp.com/partition"
@@ -35,6 +42,12 @@ const (
 	DefaultPartition = "default"
 	// DefaultNamespace is the name of the default Consul namespace.
 	DefaultNamespace = "default"
+
+	// DefaultDescribeTasksConcurrency is the default number of concurrent DescribeTasks calls.
+	DefaultDescribeTasksConcurrency = 4
+
+	// maxDescribeTasks is the maximum number of tasks accepted by a single DescribeTasks call.
+	maxDescribeTasks = 100
 )
 
 type TaskID string
@@ -80,8 +93,40 @@ type TaskStateLister struct {
 	// If partition and namespace support are not enabled then this is set to the empty string.
 	Partition string
 
+	// RequiredTags are the tags, in addition to the mesh tag, that a task must
+	// have for the controller to manage it. All tags must match.
+	RequiredTags map[string]string
+
+	// ExcludeFamilies match the task definition families that the controller
+	// does not manage, even if their tasks have the mesh tag. A family is
+	// excluded if any of the expressions matches the whole family name.
+	// The tokens and services of excluded families are not cleaned up, even
+	// after their tasks stop. Tokens and services that do not record their
+	// family, such as those created by older versions of mesh-init, are not
+	// excluded.
+	ExcludeFamilies []*regexp.Regexp
+
+	// DescribeTasksConcurrency is the maximum number of concurrent DescribeTasks calls.
+	// Defaults to DefaultDescribeTasksConcurrency if zero.
+	DescribeTasksConcurrency int
+
+	// Throttling tracks the throttling of the ECS calls across reconciles, to
+	// reduce the calls while the throttling persists. It is disabled if nil.
+	Throttling *Throttling
+
+	// newThrottleBackOff returns the backoff for retrying throttled ECS calls.
+	// Defaults to defaultThrottleBackOff if nil.
+	newThrottleBackOff func() backoff.BackOff
+
 	// Log is the logger for the ServiceStateLister.
 	Log hclog.Logger
+
+	// Metrics records the tasks listed and the cleanup done for each task.
+	// Metrics are disabled if nil.
+	Metrics *Metrics
+
+	// AuditLog records the tokens deleted by the controller. It is disabled if nil.
+	AuditLog *AuditLog
 }
 
 // List returns resources to be reconciled.
@@ -95,10 +140,11 @@ func (s TaskStateLister) List() ([]Resource, error) {
 		return nil, err
 	}
 
-	buildingResources, err := s.fetchECSTasks()
+	buildingResources, unmanaged, err := s.fetchECSTasks()
 	if err != nil {
 		return nil, err
 	}
+	s.Metrics.setTasksListed(len(buildingResources))
 
 	aclState, err := s.fetchACLState(consulClient)
 	if err != nil {
@@ -126,6 +172,12 @@ func (s TaskStateLister) List() ([]Resource, error) {
 		}
 	}
 
+	// Leave the tokens and services of running tasks that are not managed by
+	// this controller untouched.
+	for id := range unmanaged {
+		delete(buildingResources, id)
+	}
+
 	for _, resource := range buildingResources {
 		resources = append(resources, resource)
 	}
@@ -135,9 +187,76 @@ func (s TaskStateLister) List() ([]Resource, error) {
 
 // fetchECSTasks retrieves all of the ECS tasks that are managed by consul-ecs
 // for the current cluster (s.ClusterARN) and returns a set of tasks found. Tasks which are not
-// tagged with the current partition (s.Partition) are ignored.
-func (s TaskStateLister) fetchECSTasks() (map[TaskID]*TaskState, error) {
+// tagged with the current partition (s.Partition) are ignored. The IDs of mesh tasks that
+// do not have all of s.RequiredTags, or whose family is excluded by s.ExcludeFamilies,
+// are returned separately.
+func (s TaskStateLister) fetchECSTasks() (map[TaskID]*TaskState, map[TaskID]struct{}, error) {
 	resources := make(map[TaskID]*TaskState)
+	unmanaged := make(map[TaskID]struct{})
+	defer s.Throttling.endReconcile()
+
+	taskARNs, err := s.listTaskARNs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tasks, err := s.describeTasks(taskARNs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, task := range tasks {
+		if task == nil {
+			s.Log.Warn("task is nil")
+			continue
+		}
+
+		if !isMeshTask(task) {
+			s.Log.Debug("skipping non-mesh task", "task-arn", *task.TaskArn)
+			continue
+		}
+
+		state, err := s.taskStateFromTask(task)
+		if err != nil {
+			s.Log.Error("skipping task", "task-arn", *task.TaskArn, "tags", task.Tags, "err", err)
+			continue
+		}
+
+		if state.Partition != s.Partition {
+			s.Log.Debug("skipping task in external partition", "partition", state.Partition, "task-arn", *task.TaskArn)
+			continue
+		}
+
+		if family := taskFamily(task); s.isExcludedFamily(family) {
+			s.Log.Debug("skipping task of excluded family", "family", family, "task-arn", *task.TaskArn)
+			unmanaged[state.TaskID] = struct{}{}
+			continue
+		}
+
+		if !hasTags(task, s.RequiredTags) {
+			s.Log.Debug("skipping task without required tags", "task-arn", *task.TaskArn)
+			unmanaged[state.TaskID] = struct{}{}
+			continue
+		}
+
+		resources[state.TaskID] = state
+	}
+	return resources, unmanaged, nil
+}
+
+// isExcludedFamily returns true if the family matches any of s.ExcludeFamilies.
+func (s TaskStateLister) isExcludedFamily(family string) bool {
+	for _, re := range s.ExcludeFamilies {
+		if family != "" && re.MatchString(family) {
+			return true
+		}
+	}
+	return false
+}
+
+// listTaskARNs returns the ARNs of all tasks in the cluster.
+func (s TaskStateLister) listTaskARNs() ([]*string, error) {
+	var taskARNs []*string
 
 	// nextToken is to handle paginated responses from AWS.
 	var nextToken *string
@@ -145,52 +264,96 @@ func (s TaskStateLister) fetchECSTasks() (map[TaskID]*TaskState, error) {
 	// This isn't an infinite loop, instead this is a "do while" loop
 	// because we'll break out of it as soon as nextToken is nil.
 	for {
-		taskListOutput, err := s.ECSClient.ListTasks(&ecs.ListTasksInput{
-			Cluster:   aws.String(s.ClusterARN),
-			NextToken: nextToken,
+		var taskListOutput *ecs.ListTasksOutput
+		err := s.retryThrottled(func() error {
+			var err error
+			taskListOutput, err = s.ECSClient.ListTasks(&ecs.ListTasksInput{
+				Cluster:   aws.String(s.ClusterARN),
+				NextToken: nextToken,
+			})
+			return err
 		})
 		if err != nil {
 			return nil, fmt.Errorf("listing tasks: %w", err)
 		}
-		nextToken = taskListOutput.NextToken
+		taskARNs = append(taskARNs, taskListOutput.TaskArns...)
 
-		tasks, err := s.ECSClient.DescribeTasks(&ecs.DescribeTasksInput{
-			Cluster: aws.String(s.ClusterARN),
-			Tasks:   taskListOutput.TaskArns,
-			Include: []*string{aws.String("TAGS")},
-		})
-		if err != nil {
-			return nil, fmt.Errorf("describing tasks: %w", err)
+		nextToken = taskListOutput.NextToken
+		if nextToken == nil {
+			break
 		}
-		for _, task := range tasks.Tasks {
-			if task == nil {
-				s.Log.Warn("task is nil")
-				continue
-			}
-
-			if !isMeshTask(task) {
-				s.Log.Debug("skipping non-mesh task", "task-arn", *task.TaskArn)
-				continue
-			}
+	}
+	return taskARNs, nil
+}
 
-			state, err := s.taskStateFromTask(task)
+// describeTasks describes the given tasks in batches of at most maxDescribeTasks.
+// Up to s.DescribeTasksConcurrency batches are described concurrently, or one at a
+// time while the ECS calls are throttled. The first error encountered is returned.
+func (s TaskStateLister) describeTasks(taskARNs []*string) ([]*ecs.Task, error) {
+	var batches [][]*string
+	for len(taskARNs) > 0 {
+		n := len(taskARNs)
+		if n > maxDescribeTasks {
+			n = maxDescribeTasks
+		}
+		batches = append(batches, taskARNs[:n])
+		taskARNs = taskARNs[n:]
+	}
+
+	concurrency := s.describeTasksConcurrency()
+
+	results := make([][]*ecs.Task, len(batches))
+	errs := make([]error, len(batches))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, batch []*string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			var output *ecs.DescribeTasksOutput
+			err := s.retryThrottled(func() error {
+				var err error
+				output, err = s.ECSClient.DescribeTasks(&ecs.DescribeTasksInput{
+					Cluster: aws.String(s.ClusterARN),
+					Tasks:   batch,
+					Include: []*string{aws.String("TAGS")},
+				})
+				return err
+			})
 			if err != nil {
-				s.Log.Error("skipping task", "task-arn", *task.TaskArn, "tags", task.Tags, "err", err)
-				continue
-			}
-
-			if state.Partition != s.Partition {
-				s.Log.Debug("skipping task in external partition", "partition", state.Partition, "task-arn", *task.TaskArn)
-				continue
+				errs[i] = fmt.Errorf("describing tasks: %w", err)
+				return
 			}
+			results[i] = output.Tasks
+		}(i, batch)
+	}
+	wg.Wait()
 
-			resources[state.TaskID] = state
-		}
-		if nextToken == nil {
-			break
+	var tasks []*ecs.Task
+	for i := range batches {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
+		tasks = append(tasks, results[i]...)
 	}
-	return resources, nil
+	return tasks, nil
+}
+
+// describeTasksConcurrency returns the number of batches of tasks to describe
+// concurrently.
+func (s TaskStateLister) describeTasksConcurrency() int {
+	if s.Throttling.open() {
+		s.Log.Warn("ECS calls are throttled; describing one batch of tasks at a time")
+		return 1
+	}
+	if s.DescribeTasksConcurrency <= 0 {
+		return DefaultDescribeTasksConcurrency
+	}
+	return s.DescribeTasksConcurrency
 }
 
 // fetchACLState retrieves all of the ACL tokens from Consul (in this partition)
@@ -232,6 +395,10 @@ func (s TaskStateLister) fetchACLState(consulClient *api.Client) (map[TaskID]*Ta
 			if s.ClusterARN != state.ClusterARN {
 				continue
 			}
+			if s.isExcludedFamily(state.Family) {
+				s.Log.Debug("skipping token of excluded family", "family", state.Family, "token", token.AccessorID)
+				continue
+			}
 			if found, ok := aclState[state.TaskID]; ok {
 				found.ACLTokens = append(found.ACLTokens, token)
 			} else {
@@ -264,6 +431,11 @@ func (s TaskStateLister) fetchServiceStateForTasks(consulClient *api.Client) (ma
 		}
 
 		state := s.newTaskState(taskID, s.ClusterARN)
+		state.Family = service.Meta[serviceFamilyMeta]
+		if s.isExcludedFamily(state.Family) {
+			s.Log.Debug("skipping service of excluded family", "family", state.Family, "service", service.ID)
+			continue
+		}
 
 		if found, ok := serviceState[state.TaskID]; ok {
 			found.Services = append(found.Services, service)
@@ -407,6 +579,8 @@ func (s TaskStateLister) newTaskState(taskId TaskID, clusterArn string) *TaskSta
 	return &TaskState{
 		SetupConsulClientFn: s.SetupConsulClientFn,
 		Log:                 s.Log,
+		Metrics:             s.Metrics,
+		AuditLog:            s.AuditLog,
 		TaskID:              taskId,
 		ClusterARN:          clusterArn,
 	}
@@ -433,6 +607,7 @@ func (s TaskStateLister) taskStateFromTask(t *ecs.Task) (*TaskState, error) {
 
 	ts := s.newTaskState(TaskID(taskId), *t.ClusterArn)
 	ts.ECSTaskFound = true
+	ts.Family = taskFamily(t)
 	ts.Partition = partition
 	ts.NS = namespace
 	return ts, nil
@@ -445,6 +620,7 @@ func (s TaskStateLister) taskStateFromToken(token *api.ACLTokenListEntry) (*Task
 	}
 
 	ts := s.newTaskState(meta.TaskID, meta.Cluster)
+	ts.Family = meta.Family
 	ts.ACLTokens = []*api.ACLTokenListEntry{token}
 	// Do not set the partition or namespace based on the token.
 	// We don't create namespaces based on the token, and the token struct
@@ -473,6 +649,7 @@ func parseTokenDescription(d string) (tokenMeta, error) {
 type tokenMeta struct {
 	TaskID  TaskID `json:"consul.hashicorp.com/task-id"`
 	Cluster string `json:"consul.hashicorp.com/cluster"`
+	Family  string `json:"consul.hashicorp.com/task-family"`
 }
 
 // TaskState contains the information needed to reconcile a task.
@@ -484,6 +661,9 @@ type TaskState struct {
 	TaskID TaskID
 	// ClusterARN is the ECS cluster.
 	ClusterARN string
+	// Family is the task definition family of the task. It is empty if the
+	// family is unknown, such as for tokens that do not record it.
+	Family string
 	// Partition that the task belongs to [Consul Enterprise].
 	Partition string
 	// Namespace that the task belongs to [Consul Enterprise].
@@ -496,7 +676,9 @@ type TaskState struct {
 	// Service and the sidecar proxy registrations associated with this ECS task
 	Services []*api.AgentService
 
-	Log hclog.Logger
+	Log      hclog.Logger
+	Metrics  *Metrics
+	AuditLog *AuditLog
 }
 
 // Reconcile deletes ACL tokens and removes the service from Catalog
@@ -542,6 +724,10 @@ func (t *TaskState) Delete(consulClient *api.Client) error {
 			return fmt.Errorf("deleting token: %w", err)
 		}
 		t.Log.Info("token deleted successfully", "token", token.Description)
+		t.Metrics.incTokensDeleted()
+		if err := t.AuditLog.tokenDeleted(t, token); err != nil {
+			t.Log.Error("failed to write audit event", "event", AuditEventTokenDeleted, "accessor-id", token.AccessorID, "err", err)
+		}
 	}
 	return nil
 }
@@ -561,7 +747,9 @@ func (t *TaskState) DeregisterServices(consulClient *api.Client) error {
 		_, err := consulClient.Catalog().Deregister(deregInput, opts)
 		if err != nil {
 			result = multierror.Append(result, fmt.Errorf("deregistering service with ID %s: %w", svc.ID, err))
+			continue
 		}
+		t.Metrics.incServicesDeregistered()
 	}
 	return nil
 }
@@ -598,6 +786,50 @@ func isMeshTask(t *ecs.Task) bool {
 	return tagValue(t.Tags, meshTag) == "true"
 }
 
+// taskFamily returns the family of the task's task definition, or the empty
+// string if the task definition ARN cannot be parsed.
+func taskFamily(t *ecs.Task) string {
+	// The task definition ARN is of the form
+	// arn:aws:ecs:<region>:<account>:task-definition/<family>:<revision>
+	taskDefARN := aws.StringValue(t.TaskDefinitionArn)
+	_, familyAndRevision, ok := strings.Cut(taskDefARN, "task-definition/")
+	if !ok {
+		return ""
+	}
+	family, _, _ := strings.Cut(familyAndRevision, ":")
+	return family
+}
+
+// CompileFamilyPatterns compiles the comma-separated regular expressions of
+// task definition families, such as `legacy-app,batch-.*`. Each expression
+// must match the whole family name, so a plain family name only matches
+// itself.
+func CompileFamilyPatterns(value string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, expr := range strings.Split(value, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid family pattern %q: %w", expr, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// hasTags returns true if the task has all of the given tag key/value pairs.
+func hasTags(t *ecs.Task, tags map[string]string) bool {
+	for key, value := range tags {
+		if tagValue(t.Tags, key) != value {
+			return false
+		}
+	}
+	return true
+}
+
 func tagValue(tags []*ecs.Tag, key string) string {
 	for _, t := range tags {
 		if t.Key != nil && *t.Key == key {
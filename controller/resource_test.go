@@ -20,12 +20,16 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"regexp"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -293,6 +297,291 @@ func TestTaskStateListerList(t *testing.T) {
 	}
 }
 
+func TestFetchECSTasks(t *testing.T) {
+	var tasks []*ecs.Task
+	for i := 0; i < 250; i++ {
+		tasks = append(tasks, makeECSTask(t, fmt.Sprintf("mesh-task-id-%d", i), meshTag, "true"))
+	}
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, makeECSTask(t, fmt.Sprintf("non-mesh-task-id-%d", i)))
+	}
+
+	cases := map[string]struct {
+		ecsClient   *mocks.ECSClient
+		concurrency int
+		expCalls    int
+		expError    string
+	}{
+		"multiple pages": {
+			ecsClient: &mocks.ECSClient{Tasks: tasks, PageSize: 100},
+			expCalls:  3,
+		},
+		"pages larger than DescribeTasks limit": {
+			ecsClient:   &mocks.ECSClient{Tasks: tasks, PageSize: 260},
+			concurrency: 1,
+			expCalls:    3,
+		},
+		"describe error": {
+			ecsClient: &mocks.ECSClient{Tasks: tasks, PageSize: 100, DescribeTasksErr: fmt.Errorf("throttled")},
+			expCalls:  3,
+			expError:  "describing tasks: throttled",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := TaskStateLister{
+				ECSClient:                c.ecsClient,
+				ClusterARN:               testClusterArn,
+				DescribeTasksConcurrency: c.concurrency,
+				Log:                      hclog.NewNullLogger(),
+			}
+			resources, _, err := s.fetchECSTasks()
+			require.Equal(t, c.expCalls, c.ecsClient.DescribeTasksCalls())
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, resources, 250)
+			for i := 0; i < 250; i++ {
+				require.Contains(t, resources, TaskID(fmt.Sprintf("mesh-task-id-%d", i)))
+			}
+		})
+	}
+}
+
+func TestFetchECSTasksRequiredTags(t *testing.T) {
+	requiredTags := map[string]string{"environment": "test", "team": "payments"}
+	tasks := []*ecs.Task{
+		makeECSTask(t, "all-tags", meshTag, "true", "environment", "test", "team", "payments"),
+		makeECSTask(t, "some-tags", meshTag, "true", "environment", "test"),
+		makeECSTask(t, "wrong-value", meshTag, "true", "environment", "prod", "team", "payments"),
+		makeECSTask(t, "no-tags", meshTag, "true"),
+		makeECSTask(t, "non-mesh", "environment", "test", "team", "payments"),
+	}
+
+	cases := map[string]struct {
+		requiredTags map[string]string
+		expManaged   []TaskID
+		expUnmanaged []TaskID
+	}{
+		"no required tags": {
+			expManaged: []TaskID{"all-tags", "some-tags", "wrong-value", "no-tags"},
+		},
+		"required tags": {
+			requiredTags: requiredTags,
+			expManaged:   []TaskID{"all-tags"},
+			expUnmanaged: []TaskID{"some-tags", "wrong-value", "no-tags"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := TaskStateLister{
+				ECSClient:    &mocks.ECSClient{Tasks: tasks},
+				ClusterARN:   testClusterArn,
+				RequiredTags: c.requiredTags,
+				Log:          hclog.NewNullLogger(),
+			}
+			resources, unmanaged, err := s.fetchECSTasks()
+			require.NoError(t, err)
+
+			var managedIDs, unmanagedIDs []TaskID
+			for id := range resources {
+				managedIDs = append(managedIDs, id)
+			}
+			for id := range unmanaged {
+				unmanagedIDs = append(unmanagedIDs, id)
+			}
+			require.ElementsMatch(t, c.expManaged, managedIDs)
+			require.ElementsMatch(t, c.expUnmanaged, unmanagedIDs)
+		})
+	}
+}
+
+func TestFetchECSTasksExcludeFamilies(t *testing.T) {
+	withFamily := func(task *ecs.Task, family string) *ecs.Task {
+		task.TaskDefinitionArn = aws.String("arn:aws:ecs:bogus-east-1:000000000000:task-definition/" + family + ":3")
+		return task
+	}
+	tasks := []*ecs.Task{
+		withFamily(makeECSTask(t, "app", meshTag, "true"), "app"),
+		withFamily(makeECSTask(t, "app-canary", meshTag, "true"), "app-canary"),
+		withFamily(makeECSTask(t, "batch-nightly", meshTag, "true"), "batch-nightly"),
+		withFamily(makeECSTask(t, "batch-hourly", meshTag, "true"), "batch-hourly"),
+		makeECSTask(t, "no-task-definition", meshTag, "true"),
+	}
+
+	cases := map[string]struct {
+		excludeFamilies string
+		expManaged      []TaskID
+		expUnmanaged    []TaskID
+	}{
+		"no exclusions": {
+			expManaged: []TaskID{"app", "app-canary", "batch-nightly", "batch-hourly", "no-task-definition"},
+		},
+		"literal family": {
+			excludeFamilies: "app",
+			expManaged:      []TaskID{"app-canary", "batch-nightly", "batch-hourly", "no-task-definition"},
+			expUnmanaged:    []TaskID{"app"},
+		},
+		"regular expression": {
+			excludeFamilies: "batch-.*",
+			expManaged:      []TaskID{"app", "app-canary", "no-task-definition"},
+			expUnmanaged:    []TaskID{"batch-nightly", "batch-hourly"},
+		},
+		"literal and regular expression": {
+			excludeFamilies: "app-canary, batch-(nightly|weekly)",
+			expManaged:      []TaskID{"app", "batch-hourly", "no-task-definition"},
+			expUnmanaged:    []TaskID{"app-canary", "batch-nightly"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			excludeFamilies, err := CompileFamilyPatterns(c.excludeFamilies)
+			require.NoError(t, err)
+
+			s := TaskStateLister{
+				ECSClient:       &mocks.ECSClient{Tasks: tasks},
+				ClusterARN:      testClusterArn,
+				ExcludeFamilies: excludeFamilies,
+				Log:             hclog.NewNullLogger(),
+			}
+			resources, unmanaged, err := s.fetchECSTasks()
+			require.NoError(t, err)
+
+			var managedIDs, unmanagedIDs []TaskID
+			for id := range resources {
+				managedIDs = append(managedIDs, id)
+			}
+			for id := range unmanaged {
+				unmanagedIDs = append(unmanagedIDs, id)
+			}
+			require.ElementsMatch(t, c.expManaged, managedIDs)
+			require.ElementsMatch(t, c.expUnmanaged, unmanagedIDs)
+		})
+	}
+}
+
+// TestListExcludeFamiliesStoppedTasks checks that the tokens and services of
+// stopped tasks of an excluded family are not cleaned up.
+func TestListExcludeFamiliesStoppedTasks(t *testing.T) {
+	makeFamilyToken := func(taskID, family string) *api.ACLTokenListEntry {
+		token := makeToken(t, taskID, true)
+		token.Description = fmt.Sprintf(
+			`token created via login: {"%s":"%s","%s":"%s","%s":"%s"}`,
+			clusterTag, testClusterArn, taskIdTag, taskID, taskFamilyTag, family,
+		)
+		return token
+	}
+	tokens := []*api.ACLTokenListEntry{
+		makeFamilyToken("legacy-task", "legacy-app"),
+		makeFamilyToken("mesh-task", "mesh-app"),
+		makeToken(t, "unknown-family-task", true),
+	}
+
+	var services []*api.AgentService
+	for _, reg := range []*api.CatalogRegistration{
+		constructSvcRegInput(testClusterArn, "legacy", "legacy-task"),
+		constructSvcRegInput(testClusterArn, "mesh", "mesh-task"),
+		constructSvcRegInput(testClusterArn, "unknown", "unknown-family-task"),
+	} {
+		services = append(services, reg.Service)
+	}
+	services[0].Meta[serviceFamilyMeta] = "legacy-app"
+	services[1].Meta[serviceFamilyMeta] = "mesh-app"
+
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/acl/tokens":
+			require.NoError(t, json.NewEncoder(w).Encode(tokens))
+		case strings.HasPrefix(r.URL.Path, "/v1/catalog/node-services/"):
+			require.NoError(t, json.NewEncoder(w).Encode(api.CatalogNodeServiceList{Services: services}))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(consulServer.Close)
+
+	consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	excludeFamilies, err := CompileFamilyPatterns("legacy-.*")
+	require.NoError(t, err)
+
+	// All of the tasks are stopped, so ECS lists none of them.
+	s := TaskStateLister{
+		ECSClient:           &mocks.ECSClient{},
+		SetupConsulClientFn: func() (*api.Client, error) { return consulClient, nil },
+		ClusterARN:          testClusterArn,
+		ExcludeFamilies:     excludeFamilies,
+		Log:                 hclog.NewNullLogger(),
+	}
+	resources, err := s.List()
+	require.NoError(t, err)
+
+	reaped := make(map[TaskID]*TaskState)
+	for _, r := range resources {
+		state := r.(*TaskState)
+		require.False(t, state.IsPresent())
+		reaped[state.TaskID] = state
+	}
+	require.Len(t, reaped, 2)
+	require.NotContains(t, reaped, TaskID("legacy-task"))
+
+	require.Equal(t, "mesh-app", reaped["mesh-task"].Family)
+	require.Len(t, reaped["mesh-task"].ACLTokens, 1)
+	require.Len(t, reaped["mesh-task"].Services, 1)
+
+	// Tokens and services that do not record their family are still cleaned up.
+	require.Len(t, reaped["unknown-family-task"].ACLTokens, 1)
+	require.Len(t, reaped["unknown-family-task"].Services, 1)
+}
+
+func TestCompileFamilyPatterns(t *testing.T) {
+	patterns, err := CompileFamilyPatterns("")
+	require.NoError(t, err)
+	require.Empty(t, patterns)
+
+	patterns, err = CompileFamilyPatterns("app, ,batch-.*")
+	require.NoError(t, err)
+	require.Len(t, patterns, 2)
+
+	_, err = CompileFamilyPatterns("app,batch-(")
+	require.ErrorContains(t, err, `invalid family pattern "batch-("`)
+}
+
+// TestFetchACLStateRequests checks that the ACL state is built from the token
+// list alone, without reading each token.
+func TestFetchACLStateRequests(t *testing.T) {
+	var tokens []*api.ACLTokenListEntry
+	for i := 0; i < 100; i++ {
+		tokens = append(tokens, makeToken(t, fmt.Sprintf("mesh-task-id-%d", i), true))
+	}
+	tokens = append(tokens, makeToken(t, "non-mesh-task-id", false))
+
+	var tokenListCalls, otherCalls int32
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/acl/tokens" {
+			atomic.AddInt32(&otherCalls, 1)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		atomic.AddInt32(&tokenListCalls, 1)
+		require.NoError(t, json.NewEncoder(w).Encode(tokens))
+	}))
+	t.Cleanup(consulServer.Close)
+
+	consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	s := TaskStateLister{ClusterARN: testClusterArn, Log: hclog.NewNullLogger()}
+	aclState, err := s.fetchACLState(consulClient)
+	require.NoError(t, err)
+	require.Len(t, aclState, 100)
+	require.Equal(t, int32(1), atomic.LoadInt32(&tokenListCalls))
+	require.Zero(t, atomic.LoadInt32(&otherCalls))
+}
+
 func TestTaskStateReconcile(t *testing.T) {
 	t.Parallel()
 
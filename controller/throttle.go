@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/cenkalti/backoff/v4"
+)
+
+const (
+	// throttleRetryInitialInterval, throttleRetryMaxInterval and
+	// throttleRetryMaxElapsedTime bound the retries of a throttled AWS call
+	// within a reconcile. The AWS SDK also retries throttled calls a few times
+	// before the error is returned.
+	throttleRetryInitialInterval = 1 * time.Second
+	throttleRetryMaxInterval     = 10 * time.Second
+	throttleRetryMaxElapsedTime  = 30 * time.Second
+
+	// throttlingBreakerThreshold is the number of consecutive reconciles with
+	// throttled AWS calls after which the controller reduces its AWS calls.
+	throttlingBreakerThreshold = 3
+)
+
+// Throttling tracks the throttling of the controller's AWS calls across
+// reconciles. When the calls of several consecutive reconciles are throttled,
+// the breaker opens and the controller describes one batch of tasks at a time,
+// until a reconcile completes without being throttled. Its methods are no-ops
+// on a nil *Throttling.
+type Throttling struct {
+	mu sync.Mutex
+	// throttled is whether a call of the current reconcile was throttled.
+	throttled bool
+	// consecutive is the number of consecutive reconciles with throttled calls.
+	consecutive int
+}
+
+// observeThrottle records that a call of the current reconcile was throttled.
+func (t *Throttling) observeThrottle() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.throttled = true
+}
+
+// endReconcile records the end of a reconcile.
+func (t *Throttling) endReconcile() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.throttled {
+		t.consecutive++
+	} else {
+		t.consecutive = 0
+	}
+	t.throttled = false
+}
+
+// open returns true if the calls of the last throttlingBreakerThreshold
+// reconciles were throttled.
+func (t *Throttling) open() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.consecutive >= throttlingBreakerThreshold
+}
+
+// defaultThrottleBackOff returns the backoff for retrying throttled AWS calls.
+func defaultThrottleBackOff() backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = throttleRetryInitialInterval
+	b.MaxInterval = throttleRetryMaxInterval
+	b.MaxElapsedTime = throttleRetryMaxElapsedTime
+	b.Reset()
+	return b
+}
+
+// retryThrottled calls op until it succeeds, fails with an error other than
+// AWS throttling, or the throttle backoff gives up. Throttled calls are
+// recorded in s.Throttling.
+func (s TaskStateLister) retryThrottled(op func() error) error {
+	newBackOff := s.newThrottleBackOff
+	if newBackOff == nil {
+		newBackOff = defaultThrottleBackOff
+	}
+	return backoff.RetryNotify(func() error {
+		err := op()
+		if err != nil && !request.IsErrorThrottle(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, newBackOff(), func(err error, wait time.Duration) {
+		s.Throttling.observeThrottle()
+		s.Log.Warn("AWS request throttled; retrying", "err", err, "wait", wait)
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/consul-ecs/controller/mocks"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchECSTasksThrottled(t *testing.T) {
+	var tasks []*ecs.Task
+	for i := 0; i < 250; i++ {
+		tasks = append(tasks, makeECSTask(t, fmt.Sprintf("mesh-task-id-%d", i), meshTag, "true"))
+	}
+
+	cases := map[string]struct {
+		listTasksThrottles     int
+		describeTasksThrottles int
+		maxRetries             uint64
+		expError               string
+	}{
+		"list tasks throttled then succeeds": {
+			listTasksThrottles: 2,
+			maxRetries:         2,
+		},
+		"describe tasks throttled then succeeds": {
+			describeTasksThrottles: 3,
+			maxRetries:             3,
+		},
+		"throttled until the retries give up": {
+			listTasksThrottles: 3,
+			maxRetries:         2,
+			expError:           "listing tasks: ThrottlingException: Rate exceeded",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ecsClient := &mocks.ECSClient{
+				Tasks:                  tasks,
+				ListTasksThrottles:     c.listTasksThrottles,
+				DescribeTasksThrottles: c.describeTasksThrottles,
+			}
+			throttling := &Throttling{}
+			s := TaskStateLister{
+				ECSClient:  ecsClient,
+				ClusterARN: testClusterArn,
+				Throttling: throttling,
+				Log:        hclog.NewNullLogger(),
+				newThrottleBackOff: func() backoff.BackOff {
+					return backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Millisecond), c.maxRetries)
+				},
+			}
+
+			resources, _, err := s.fetchECSTasks()
+			require.Equal(t, 1, throttling.consecutive)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, resources, 250)
+		})
+	}
+}
+
+func TestFetchECSTasksNotThrottledErrorIsNotRetried(t *testing.T) {
+	ecsClient := &mocks.ECSClient{
+		Tasks:            []*ecs.Task{makeECSTask(t, "mesh-task-id", meshTag, "true")},
+		DescribeTasksErr: fmt.Errorf("access denied"),
+	}
+	throttling := &Throttling{}
+	s := TaskStateLister{
+		ECSClient:  ecsClient,
+		ClusterARN: testClusterArn,
+		Throttling: throttling,
+		Log:        hclog.NewNullLogger(),
+		newThrottleBackOff: func() backoff.BackOff {
+			return backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Millisecond), 3)
+		},
+	}
+
+	_, _, err := s.fetchECSTasks()
+	require.EqualError(t, err, "describing tasks: access denied")
+	require.Equal(t, 1, ecsClient.DescribeTasksCalls())
+	require.Zero(t, throttling.consecutive)
+}
+
+func TestThrottlingBreaker(t *testing.T) {
+	throttling := &Throttling{}
+	s := TaskStateLister{
+		DescribeTasksConcurrency: 8,
+		Throttling:               throttling,
+		Log:                      hclog.NewNullLogger(),
+	}
+
+	// The breaker opens after consecutive throttled reconciles.
+	for i := 0; i < throttlingBreakerThreshold; i++ {
+		require.Equal(t, 8, s.describeTasksConcurrency())
+		throttling.observeThrottle()
+		throttling.endReconcile()
+	}
+	require.Equal(t, 1, s.describeTasksConcurrency())
+
+	// It stays open while the throttling persists.
+	throttling.observeThrottle()
+	throttling.endReconcile()
+	require.Equal(t, 1, s.describeTasksConcurrency())
+
+	// It closes after a reconcile that is not throttled.
+	throttling.endReconcile()
+	require.Equal(t, 8, s.describeTasksConcurrency())
+
+	// A throttled reconcile between unthrottled ones does not open it.
+	throttling.observeThrottle()
+	throttling.endReconcile()
+	throttling.endReconcile()
+	throttling.observeThrottle()
+	throttling.endReconcile()
+	require.Equal(t, 8, s.describeTasksConcurrency())
+
+	// A nil Throttling is never open.
+	s.Throttling = nil
+	require.Equal(t, 8, s.describeTasksConcurrency())
+}
@@ -13,7 +13,16 @@
 // - First, write the preamble to stdout (see ./preamble.mdx).
 // - For each object or array type in schema.json, render a template to stdout (see ./properties.tpl)
 //
-// Edit the <repoRoot>/config/schema.json to modify descriptions.
+// Fields composed with `oneOf` or `anyOf` list their alternatives in the description.
+// A field with a `pattern` key has "Must match `regex`." appended to its description, and
+// a field with a `default` key has "Defaults to `X`." appended to its description.
+//
+// Properties are rendered with required fields first, then alphabetically, so
+// that the output is the same on every run.
+//
+// Edit the <repoRoot>/config/schema.json to modify descriptions. A field may set a
+// `descriptionMarkdown` key next to its `description`, which is rendered instead of
+// the `description` when richer markdown, such as links, is needed.
 // After editing the schema.json, re-run this script to update the Consul ECS documentation.
 // The generated markdown should be included in ECS docs in the Consul repo.
 
@@ -26,7 +35,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"sort"
 	"strings"
 	"text/template"
 
@@ -74,6 +82,11 @@ func getTemplate(path string) *template.Template {
 // RenderTemplates walks through the schema recursively to generate markdown.
 // It writes directly to the provided io.Writer.
 func RenderTemplates(path string, schema *Schema, wr io.Writer) {
+	if len(schema.Type) == 0 {
+		// Fields composed with `oneOf` or `anyOf` are described inline in their parent's table.
+		return
+	}
+
 	if schema.Type[0] == "array" {
 		itemSchema := schema.Items
 		RenderTemplates(path, itemSchema, wr)
@@ -89,7 +102,7 @@ func RenderTemplates(path string, schema *Schema, wr io.Writer) {
 			log.Fatal(err)
 		}
 
-		for _, field := range sortedKeys(schema.Properties) {
+		for _, field := range schema.SortedProperties() {
 			propSchema := schema.Properties[field]
 			propPath := strings.Trim(path+"."+field, ".")
 			RenderTemplates(propPath, propSchema, wr)
@@ -97,15 +110,6 @@ func RenderTemplates(path string, schema *Schema, wr io.Writer) {
 	}
 }
 
-func sortedKeys(props map[string]*Schema) []string {
-	result := make([]string, 0, len(props))
-	for k := range props {
-		result = append(result, k)
-	}
-	sort.Strings(result)
-	return result
-}
-
 func main() {
 	var schema Schema
 	if err := json.Unmarshal([]byte(config.Schema), &schema); err != nil {
@@ -0,0 +1,166 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// render parses the schema JSON and returns the generated markdown.
+func render(t *testing.T, schemaJSON string) string {
+	var schema Schema
+	require.NoError(t, json.Unmarshal([]byte(schemaJSON), &schema))
+
+	var buf bytes.Buffer
+	RenderTemplates("", &schema, &buf)
+	return buf.String()
+}
+
+func TestRenderDescriptionMarkdown(t *testing.T) {
+	output := render(t, `{
+		"type": "object",
+		"properties": {
+			"plain": {
+				"description": "A plain description.",
+				"type": "string"
+			},
+			"rich": {
+				"description": "A plain description of the rich field.",
+				"descriptionMarkdown": "See the [docs](https://developer.hashicorp.com/consul) for details.",
+				"type": "string"
+			},
+			"enterprise": {
+				"description": "Plain enterprise field [Consul Enterprise].",
+				"descriptionMarkdown": "Rich enterprise field [Consul Enterprise].",
+				"type": "string"
+			}
+		}
+	}`)
+
+	require.Contains(t, output, "| `plain` | `string` | optional | A plain description.  |")
+	require.Contains(t, output, "| `rich` | `string` | optional | See the [docs](https://developer.hashicorp.com/consul) for details.  |")
+	require.NotContains(t, output, "A plain description of the rich field.")
+	require.Contains(t, output, "| `enterprise` | `string` | optional | <EnterpriseAlert inline /> Rich enterprise field.  |")
+	require.NotContains(t, output, "Plain enterprise field")
+}
+
+func TestRenderStableOrder(t *testing.T) {
+	schemaJSON := `{
+		"type": "object",
+		"required": ["zulu"],
+		"properties": {
+			"zulu": {"description": "Zulu.", "type": "string"},
+			"bravo": {"description": "Bravo.", "type": "string"},
+			"alpha": {
+				"description": "Alpha.",
+				"type": "object",
+				"required": ["yankee"],
+				"properties": {
+					"xray": {"description": "Xray.", "type": "string"},
+					"yankee": {"description": "Yankee.", "type": "string"},
+					"delta": {"description": "Delta.", "type": "string"}
+				}
+			},
+			"charlie": {
+				"description": "Charlie.",
+				"type": "array",
+				"items": {
+					"type": "object",
+					"properties": {
+						"mike": {"description": "Mike.", "type": "string"},
+						"kilo": {"description": "Kilo.", "type": "string"}
+					}
+				}
+			}
+		}
+	}`
+
+	first := render(t, schemaJSON)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, first, render(t, schemaJSON))
+	}
+
+	requireOrder := func(names ...string) {
+		t.Helper()
+		last := -1
+		for _, name := range names {
+			idx := strings.Index(first, "`"+name+"`")
+			require.Greater(t, idx, last, "expected %q after the previous property", name)
+			last = idx
+		}
+	}
+	requireOrder("zulu", "alpha", "bravo", "charlie")
+	requireOrder("yankee", "delta", "xray")
+	requireOrder("kilo", "mike")
+}
+
+func TestRenderOneOf(t *testing.T) {
+	output := render(t, `{
+		"type": "object",
+		"properties": {
+			"address": {
+				"description": "The address to dial.",
+				"oneOf": [
+					{"description": "A host or IP address.", "type": "string"},
+					{
+						"type": "object",
+						"properties": {
+							"port": {"type": "integer"},
+							"address": {"type": "string"}
+						}
+					}
+				]
+			},
+			"target": {
+				"description": "The target.",
+				"anyOf": [
+					{"type": "string"},
+					{"type": ["integer", "null"]}
+				]
+			}
+		}
+	}`)
+
+	require.Contains(t, output, "| `address` | `string` or `object` | optional | The address to dial. "+
+		"Must be one of: `string` (A host or IP address); `object` with fields `address`, `port`. |")
+	require.Contains(t, output, "| `target` | `string` or `integer` | optional | The target. Must be any of: `string`; `integer`. |")
+	require.NotContains(t, output, "`address.address`")
+}
+
+func TestRenderDefault(t *testing.T) {
+	output := render(t, `{
+		"type": "object",
+		"properties": {
+			"port": {"description": "The port.", "type": "integer", "default": 8443},
+			"ratio": {"description": "The ratio.", "type": "number", "default": 0.5},
+			"enabled": {"description": "Whether enabled.", "type": "boolean", "default": false},
+			"mode": {"description": "The mode.", "type": "string", "enum": ["local", "remote"], "default": "local"},
+			"name": {"description": "The name.", "type": "string"}
+		}
+	}`)
+
+	require.Contains(t, output, "| `port` | `integer` | optional | The port.  Defaults to `8443`. |")
+	require.Contains(t, output, "| `ratio` | `number` | optional | The ratio.  Defaults to `0.5`. |")
+	require.Contains(t, output, "| `enabled` | `boolean` | optional | Whether enabled.  Defaults to `false`. |")
+	require.Contains(t, output, "| `mode` | `string` | optional | The mode. Must be one of `local`, or `remote`. Defaults to `local`. |")
+	require.Contains(t, output, "| `name` | `string` | optional | The name.  |")
+}
+
+func TestRenderPattern(t *testing.T) {
+	output := render(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"description": "The name.", "type": "string", "pattern": "(^$)|(^[a-z0-9]+$)"},
+			"other": {"description": "Another field.", "type": "string"}
+		}
+	}`)
+
+	require.Contains(t, output, "| `name` | `string` | optional | The name.  Must match `(^$)\\|(^[a-z0-9]+$)`. |")
+	require.Contains(t, output, "| `other` | `string` | optional | Another field.  |")
+}
@@ -2,11 +2,12 @@
 
 | Field | Type | Required | Description |
 | ----- | ---- | -------- | ----------- |
-{{- range $key, $val := .Properties }}
+{{- range $key := .SortedProperties }}
+{{- $val := index $.Properties $key }}
 {{- with $anchor := $.PropertyAnchor $key }}
-| [`{{ $key }}`](#{{ $anchor }}) | `{{ index $val.Type 0 }}` | {{ $.RequiredStr $key }} | {{ $val.DescriptionStr }} {{ $val.EnumStr }} |
+| [`{{ $key }}`](#{{ $anchor }}) | {{ $val.TypeStr }} | {{ $.RequiredStr $key }} | {{ $val.DescriptionStr }} {{ $val.EnumStr }}{{ $val.AlternativesStr }}{{ with $val.PatternStr }} {{ . }}{{ end }}{{ with $val.DefaultStr }} {{ . }}{{ end }} |
 {{- else }}
-| `{{ $key }}` | `{{ index $val.Type 0 }}` | {{ $.RequiredStr $key }} | {{ $val.DescriptionStr }} {{ $val.EnumStr }} |
+| `{{ $key }}` | {{ $val.TypeStr }} | {{ $.RequiredStr $key }} | {{ $val.DescriptionStr }} {{ $val.EnumStr }}{{ $val.AlternativesStr }}{{ with $val.PatternStr }} {{ . }}{{ end }}{{ with $val.DefaultStr }} {{ . }}{{ end }} |
 {{- end }}
 {{- end }}
 
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -21,30 +22,150 @@ type Schema struct {
 	Type        jsonschemaType     `json:"type"`
 	Properties  map[string]*Schema `json:"properties"`
 	Items       *Schema            `json:"items"`
+	OneOf       []*Schema          `json:"oneOf"`
+	AnyOf       []*Schema          `json:"anyOf"`
 
-	AdditionalProperties bool      `json:"additionalProperties"`
+	AdditionalProperties any       `json:"additionalProperties"` // bool or a schema for the values
+	Default              any       `json:"default"`
+	Pattern              string    `json:"pattern"`
 	MinLength            int       `json:"minLength"`
 	Enum                 []*string `json:"enum"`
 	MinItems             int       `json:"minItems"`
 	Required             []string  `json:"required"`
 	UniqueItems          bool      `json:"uniqueItems"`
 
+	// DescriptionMarkdown, if set, is rendered in place of Description. This allows
+	// richer markdown, such as links, than is comfortable in the plain description.
+	DescriptionMarkdown string `json:"descriptionMarkdown"`
+
 	// Extra fields for template args.
 	Path string `json:"-"`
 }
 
 // DescriptionStr returns the description modified for consul.io docs:
+//   - Prefer the DescriptionMarkdown over the Description, if set.
 //   - Remove "[Consul Enterprise]" and prefix a "<EnterpriseAlert inline />".
 //   - Remove leading space as well, so that " [Consul Enterprise]." becomes "."
 //     at the end of a sentence.
 func (s *Schema) DescriptionStr() string {
-	modified := entTagRegex.ReplaceAllString(s.Description, "")
-	if modified != s.Description {
+	description := s.Description
+	if s.DescriptionMarkdown != "" {
+		description = s.DescriptionMarkdown
+	}
+
+	modified := entTagRegex.ReplaceAllString(description, "")
+	if modified != description {
 		modified = "<EnterpriseAlert inline /> " + modified
 	}
 	return modified
 }
 
+// PatternStr returns the regex pattern for this schema in human-readable markdown, such
+// as "Must match `^[a-z]+$`." Pipes are escaped so that the pattern does not split the
+// markdown table. If this schema has no pattern, an empty string is returned.
+func (s *Schema) PatternStr() string {
+	if s.Pattern == "" {
+		return ""
+	}
+	return "Must match `" + strings.ReplaceAll(s.Pattern, "|", `\|`) + "`."
+}
+
+// DefaultStr returns the default value for this schema in human-readable markdown, such
+// as "Defaults to `8443`." If this schema has no default, an empty string is returned.
+func (s *Schema) DefaultStr() string {
+	var str string
+	switch val := s.Default.(type) {
+	case nil:
+		return ""
+	case string:
+		str = val
+		if str == "" {
+			str = `""`
+		}
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return ""
+		}
+		str = string(b)
+	}
+	return "Defaults to `" + str + "`."
+}
+
+// TypeStr returns the markdown for the type column of a table. Fields composed with
+// `oneOf` or `anyOf` need not declare a type, in which case the types of the
+// alternatives are listed instead, such as "`string` or `object`".
+func (s *Schema) TypeStr() string {
+	if len(s.Type) > 0 {
+		return "`" + s.Type[0] + "`"
+	}
+
+	var types []string
+	for _, alt := range s.alternatives() {
+		if len(alt.Type) == 0 {
+			continue
+		}
+		typ := "`" + alt.Type[0] + "`"
+		if !containsStr(types, typ) {
+			types = append(types, typ)
+		}
+	}
+	return strings.Join(types, " or ")
+}
+
+// AlternativesStr describes the `oneOf` or `anyOf` alternatives for this schema in
+// human-readable markdown, such as "Must be one of: `string`; `object` with fields
+// `address`, `port`." If this schema has no alternatives, an empty string is returned.
+func (s *Schema) AlternativesStr() string {
+	prefix := "Must be one of: "
+	if len(s.OneOf) == 0 {
+		prefix = "Must be any of: "
+	}
+
+	var strs []string
+	for _, alt := range s.alternatives() {
+		str := alt.TypeStr()
+		if fields := alt.SortedProperties(); len(fields) > 0 {
+			str += " with fields `" + strings.Join(fields, "`, `") + "`"
+		}
+		if desc := alt.DescriptionStr(); desc != "" {
+			str += " (" + strings.TrimSuffix(desc, ".") + ")"
+		}
+		strs = append(strs, str)
+	}
+	if len(strs) == 0 {
+		return ""
+	}
+	return prefix + strings.Join(strs, "; ") + "."
+}
+
+// alternatives returns the `oneOf` alternatives, or the `anyOf` alternatives if
+// there are no `oneOf` alternatives.
+func (s *Schema) alternatives() []*Schema {
+	if len(s.OneOf) > 0 {
+		return s.OneOf
+	}
+	return s.AnyOf
+}
+
+// SortedProperties returns the property names of this schema in the order they
+// are rendered: required properties first, then alphabetically.
+func (s *Schema) SortedProperties() []string {
+	result := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		result = append(result, k)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		iRequired := s.RequiredStr(result[i]) == "required"
+		jRequired := s.RequiredStr(result[j]) == "required"
+		if iRequired != jRequired {
+			return iRequired
+		}
+		return result[i] < result[j]
+	})
+	return result
+}
+
 // RequiredStr returns "required" or "optional" if the given
 // field is in the list of required fields for this schema.
 func (s *Schema) RequiredStr(field string) string {
@@ -90,6 +211,11 @@ func (s *Schema) EnumStr() string {
 func (t *Schema) PropertyAnchor(field string) string {
 	propSchema := t.Properties[field]
 
+	if len(propSchema.Type) == 0 {
+		// Fields composed with `oneOf` or `anyOf` are described inline.
+		return ""
+	}
+
 	var fieldProperties map[string]*Schema
 	switch propSchema.Type[0] {
 	case "object":
@@ -112,6 +238,15 @@ func (t *Schema) PropertyAnchor(field string) string {
 	return strings.ToLower(anchor)
 }
 
+func containsStr(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
 // Special parsing for the `type` field, which can be a string or []string.
 // Normalize the "type" to []string.
 type jsonschemaType []string
@@ -11,6 +11,9 @@ import (
 
 const (
 	localhostAddr = "127.0.0.1"
+
+	// redactedValue replaces secrets in a redacted dataplane configuration.
+	redactedValue = "redacted"
 )
 
 // GetDataplaneConfigJSONInputs are the inputs needed to
@@ -34,14 +37,41 @@ type GetDataplaneConfigJSONInput struct {
 
 	// The logLevel that will be used to configure dataplane's logger.
 	LogLevel string
+
+	// Path of the consul-ecs binary that the dataplane runs to look up the
+	// Consul servers when consulServers.cloudMap is configured.
+	ConsulECSBinaryPath string
+}
+
+// Redacted returns a copy of the inputs with the secrets in the login
+// credentials replaced, so that the generated JSON is safe to log.
+// The receiver is not modified.
+func (i *GetDataplaneConfigJSONInput) Redacted() *GetDataplaneConfigJSONInput {
+	redacted := *i
+	if i.ConsulLoginCredentials != nil {
+		creds := *i.ConsulLoginCredentials
+		if creds.Login.BearerToken != "" {
+			creds.Login.BearerToken = redactedValue
+		}
+		if creds.Static.Token != "" {
+			creds.Static.Token = redactedValue
+		}
+		redacted.ConsulLoginCredentials = &creds
+	}
+	return &redacted
 }
 
 // GetDataplaneConfigJSON returns back a configuration JSON which
 // (after writing it to a shared volume) can be used to start consul-dataplane
 func (i *GetDataplaneConfigJSONInput) GetDataplaneConfigJSON() ([]byte, error) {
+	addresses := i.ConsulServerConfig.Hosts
+	if i.ConsulServerConfig.CloudMap != nil {
+		addresses = i.ConsulServerConfig.CloudMapExecAddresses(i.ConsulECSBinaryPath)
+	}
+
 	cfg := &DataplaneConfig{
 		Consul: ConsulConfig{
-			Addresses:       i.ConsulServerConfig.Hosts,
+			Addresses:       addresses,
 			GRPCPort:        i.ConsulServerConfig.GRPC.Port,
 			SkipServerWatch: i.ConsulServerConfig.SkipServerWatch,
 		},
@@ -66,6 +66,56 @@ func TestGetDataplaneConfigJSON(t *testing.T) {
 				}
 			}`,
 		},
+		"Test JSON generation with Cloud Map discovery": {
+			input: &GetDataplaneConfigJSONInput{
+				ProxyRegistration: &api.CatalogRegistration{
+					Node: "test-node-name",
+					Service: &api.AgentService{
+						ID:      "test-side-car-123",
+						Service: "test-side-car",
+						Port:    1234,
+					},
+				},
+				ConsulServerConfig: config.ConsulServers{
+					CloudMap: &config.CloudMap{
+						Namespace: "consul.local",
+						Service:   "consul-server",
+					},
+					GRPC: config.GRPCSettings{
+						Port: 8503,
+					},
+				},
+				ConsulECSBinaryPath:  "/consul/consul-ecs",
+				ProxyHealthCheckPort: 22000,
+				LogLevel:             "INFO",
+			},
+			expectedJSON: `{
+				"consul": {
+				  "addresses": "exec=/consul/consul-ecs cloud-map-addresses -namespace consul.local -service consul-server",
+				  "grpcPort": 8503,
+				  "serverWatchDisabled": false,
+				  "tls": {
+					"disabled": true
+				  }
+				},
+				"proxy": {
+				  "nodeName": "test-node-name",
+				  "id": "test-side-car-123",
+				  "namespace": "%s",
+				  "partition": "%s"
+				},
+				"xdsServer": {
+				  "bindAddress": "127.0.0.1"
+				},
+				"envoy": {
+					"readyBindAddress": "127.0.0.1",
+					"readyBindPort": 22000
+				},
+				"logging": {
+					"logLevel": "INFO"
+				}
+			}`,
+		},
 		"Test JSON generation with TLS enabled": {
 			input: &GetDataplaneConfigJSONInput{
 				ProxyRegistration: &api.CatalogRegistration{
@@ -296,3 +346,41 @@ func TestGetDataplaneConfigJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestRedacted(t *testing.T) {
+	input := &GetDataplaneConfigJSONInput{
+		ProxyRegistration: &api.CatalogRegistration{
+			Node:    "test-node-name",
+			Service: &api.AgentService{ID: "test-side-car-123"},
+		},
+		ConsulServerConfig: config.ConsulServers{
+			Hosts: "consul.dc1",
+			GRPC:  config.GRPCSettings{Port: 8503},
+		},
+		ConsulLoginCredentials: &discovery.Credentials{
+			Type: "login",
+			Login: discovery.LoginCredential{
+				AuthMethod:  "test-iam-auth-method",
+				BearerToken: "bearer-token",
+				Meta:        map[string]string{"key": "value"},
+			},
+		},
+	}
+
+	redacted := input.Redacted()
+	require.Equal(t, "redacted", redacted.ConsulLoginCredentials.Login.BearerToken)
+	require.Equal(t, "test-iam-auth-method", redacted.ConsulLoginCredentials.Login.AuthMethod)
+	require.Equal(t, map[string]string{"key": "value"}, redacted.ConsulLoginCredentials.Login.Meta)
+
+	// The original inputs are untouched.
+	require.Equal(t, "bearer-token", input.ConsulLoginCredentials.Login.BearerToken)
+
+	redactedJSON, err := redacted.GetDataplaneConfigJSON()
+	require.NoError(t, err)
+	require.NotContains(t, string(redactedJSON), "bearer-token")
+	require.Contains(t, string(redactedJSON), `"bearerToken":"redacted"`)
+
+	// Inputs without credentials are returned as is.
+	input.ConsulLoginCredentials = nil
+	require.Equal(t, input, input.Redacted())
+}
@@ -5,6 +5,7 @@ package logging
 
 import (
 	"flag"
+	"io"
 
 	"github.com/hashicorp/consul-ecs/config"
 	"github.com/hashicorp/go-hclog"
@@ -14,6 +15,10 @@ const defaultLogLevel = "INFO"
 
 type LogOpts struct {
 	LogLevel string
+	LogJSON  bool
+
+	// output is where logs are written. Defaults to stderr. This is for unit tests.
+	output io.Writer
 }
 
 // FromConfig pulls log settings from the consul-ecs config JSON.
@@ -22,7 +27,10 @@ func FromConfig(conf *config.Config) *LogOpts {
 	if level == "" {
 		level = defaultLogLevel
 	}
-	return &LogOpts{LogLevel: level}
+	return &LogOpts{
+		LogLevel: level,
+		LogJSON:  conf.LogFormat == config.LogFormatJSON,
+	}
 }
 
 // Flags returns a FlagSet which can be used to add logging flags to a command.
@@ -36,7 +44,9 @@ func (l *LogOpts) Flags() *flag.FlagSet {
 func (l *LogOpts) Logger() hclog.Logger {
 	return hclog.New(
 		&hclog.LoggerOptions{
-			Level: hclog.LevelFromString(l.LogLevel),
+			Level:      hclog.LevelFromString(l.LogLevel),
+			JSONFormat: l.LogJSON,
+			Output:     l.output,
 		},
 	)
 }
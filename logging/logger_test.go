@@ -4,6 +4,9 @@
 package logging
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"testing"
 
 	"github.com/hashicorp/consul-ecs/config"
@@ -30,6 +33,14 @@ func TestFromConfig(t *testing.T) {
 			config:   config.Config{LogLevel: "trace"},
 			expected: LogOpts{LogLevel: "trace"},
 		},
+		"log format = json": {
+			config:   config.Config{LogFormat: "json"},
+			expected: LogOpts{LogLevel: defaultLogLevel, LogJSON: true},
+		},
+		"log format = text": {
+			config:   config.Config{LogFormat: "text"},
+			expected: LogOpts{LogLevel: defaultLogLevel},
+		},
 	}
 	for name, c := range cases {
 		c := c
@@ -64,3 +75,31 @@ func TestLogger(t *testing.T) {
 		})
 	}
 }
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	opts := LogOpts{LogLevel: "INFO", LogJSON: true, output: &buf}
+	logger := opts.Logger()
+
+	logger.Info("registering service", "service", "frontend", "id", "frontend-abcdef")
+	logger.Error("unable to find aws region", "err", "some error")
+	logger.Debug("not logged at INFO")
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line), "line is not valid JSON: %s", scanner.Text())
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, lines, 2)
+
+	require.Equal(t, "registering service", lines[0]["@message"])
+	require.Equal(t, "info", lines[0]["@level"])
+	require.Equal(t, "frontend", lines[0]["service"])
+	require.Equal(t, "frontend-abcdef", lines[0]["id"])
+
+	require.Equal(t, "unable to find aws region", lines[1]["@message"])
+	require.Equal(t, "error", lines[1]["@level"])
+}
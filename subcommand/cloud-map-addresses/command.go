@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloudmapaddresses
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
+	"github.com/hashicorp/consul-ecs/awsutil"
+	"github.com/mitchellh/cli"
+)
+
+const (
+	flagNamespace = "namespace"
+	flagService   = "service"
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flagSet       *flag.FlagSet
+	flagNamespace string
+	flagService   string
+	once          sync.Once
+
+	// client is only set by unit tests.
+	client servicediscoveryiface.ServiceDiscoveryAPI
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.StringVar(&c.flagNamespace, flagNamespace, "", "The Cloud Map namespace of the Consul servers. Required.")
+	c.flagSet.StringVar(&c.flagService, flagService, "", "The Cloud Map service of the Consul servers. Required.")
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flagSet.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("invalid flags: %s", err))
+		return 1
+	}
+
+	if len(c.flagSet.Args()) > 0 {
+		c.UI.Error(fmt.Sprintf("unexpected argument: %v", c.flagSet.Args()[0]))
+		return 1
+	}
+
+	for _, f := range []struct{ name, value string }{
+		{flagNamespace, c.flagNamespace},
+		{flagService, c.flagService},
+	} {
+		if f.value == "" {
+			c.UI.Error(fmt.Sprintf("invalid flags: -%s is required", f.name))
+			return 1
+		}
+	}
+
+	if c.client == nil {
+		taskMeta, err := awsutil.ECSTaskMetadata()
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+		clientSession, err := awsutil.NewSession(taskMeta, "cloud-map-addresses")
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+		c.client = servicediscovery.New(clientSession)
+	}
+
+	ips, err := awsutil.CloudMapInstanceIPs(c.client, c.flagNamespace, c.flagService)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(strings.Join(ips, " "))
+	return 0
+}
+
+func (c *Command) Synopsis() string {
+	return "Prints the Consul server IPs registered with AWS Cloud Map"
+}
+
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+
+	var buf strings.Builder
+	c.flagSet.SetOutput(&buf)
+	c.flagSet.PrintDefaults()
+	return `usage: consul-ecs cloud-map-addresses -namespace <namespace> -service <service>
+
+Prints the IPs of the instances registered with the Cloud Map service,
+separated by spaces. It is run by consul-ecs to discover the Consul servers
+when consulServers.cloudMap is configured, and is not meant to be run
+directly.
+
+` + buf.String()
+}
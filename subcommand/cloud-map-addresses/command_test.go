@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloudmapaddresses
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServiceDiscovery struct {
+	servicediscoveryiface.ServiceDiscoveryAPI
+
+	instances []*servicediscovery.HttpInstanceSummary
+}
+
+func (f *fakeServiceDiscovery) DiscoverInstances(*servicediscovery.DiscoverInstancesInput) (*servicediscovery.DiscoverInstancesOutput, error) {
+	return &servicediscovery.DiscoverInstancesOutput{Instances: f.instances}, nil
+}
+
+func TestFlagValidation(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		expectedErr string
+	}{
+		"missing namespace": {
+			args:        []string{"-service", "consul-server"},
+			expectedErr: "invalid flags: -namespace is required",
+		},
+		"missing service": {
+			args:        []string{"-namespace", "consul.local"},
+			expectedErr: "invalid flags: -service is required",
+		},
+		"unexpected argument": {
+			args:        []string{"-namespace", "consul.local", "-service", "consul-server", "extra"},
+			expectedErr: "unexpected argument: extra",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui, client: &fakeServiceDiscovery{}}
+			require.Equal(t, 1, cmd.Run(c.args))
+			require.Contains(t, ui.ErrorWriter.String(), c.expectedErr)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI: ui,
+		client: &fakeServiceDiscovery{
+			instances: []*servicediscovery.HttpInstanceSummary{
+				{Attributes: aws.StringMap(map[string]string{"AWS_INSTANCE_IPV4": "10.0.0.2"})},
+				{Attributes: aws.StringMap(map[string]string{"AWS_INSTANCE_IPV4": "10.0.0.1"})},
+			},
+		},
+	}
+
+	code := cmd.Run([]string{"-namespace", "consul.local", "-service", "consul-server"})
+	require.Equal(t, 0, code, ui.ErrorWriter.String())
+	require.Equal(t, "10.0.0.1 10.0.0.2", strings.TrimSpace(ui.OutputWriter.String()))
+}
+
+func TestRunNoInstances(t *testing.T) {
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui, client: &fakeServiceDiscovery{}}
+
+	code := cmd.Run([]string{"-namespace", "consul.local", "-service", "consul-server"})
+	require.Equal(t, 1, code)
+	require.Contains(t, ui.ErrorWriter.String(), "no instances with an IP address found")
+	require.Empty(t, ui.OutputWriter.String())
+}
@@ -0,0 +1,163 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package configshow
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-ecs/config"
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/cli"
+)
+
+// redactedValue replaces sensitive values in the printed config.
+const redactedValue = "<redacted>"
+
+type Command struct {
+	UI cli.Ui
+
+	flagSet *flag.FlagSet
+	once    sync.Once
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flagSet.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("invalid flags: %s", err))
+		return 1
+	}
+
+	args = c.flagSet.Args()
+	if len(args) > 1 {
+		c.UI.Error(fmt.Sprintf("unexpected argument: %v", args[1]))
+		return 1
+	}
+	var path string
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		c.UI.Error("invalid config:")
+		var merr *multierror.Error
+		if errors.As(err, &merr) {
+			for _, e := range merr.Errors {
+				c.UI.Error(fmt.Sprintf("  * %s", e))
+			}
+		} else {
+			c.UI.Error(fmt.Sprintf("  * %s", err))
+		}
+		return 1
+	}
+
+	output, err := effectiveConfigJSON(cfg)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("formatting the effective config: %s", err))
+		return 1
+	}
+	c.UI.Output(string(output))
+	return 0
+}
+
+// effectiveConfigJSON returns the config with its defaults applied as
+// indented JSON, with sensitive values redacted.
+func effectiveConfigJSON(cfg *config.Config) ([]byte, error) {
+	effective, err := cfg.Effective()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(effective)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode into generic values to redact the free-form fields, such as meta
+	// and proxy.config, that may contain inlined credentials.
+	var raw interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	redact(raw)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(raw); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// redact replaces the string values in v whose key names a token, password or
+// secret. Secret ARNs and file paths are references rather than secret values,
+// so they are kept.
+func redact(v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			if _, ok := child.(string); ok && isSensitiveKey(key) {
+				val[key] = redactedValue
+				continue
+			}
+			redact(child)
+		}
+	case []interface{}:
+		for _, child := range val {
+			redact(child)
+		}
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasSuffix(key, "arn") || strings.HasSuffix(key, "file") {
+		return false
+	}
+	for _, word := range []string{"token", "password", "secret"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Command) Synopsis() string {
+	return "Prints the effective consul-ecs config"
+}
+
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+
+	var buf strings.Builder
+	c.flagSet.SetOutput(&buf)
+	c.flagSet.PrintDefaults()
+	return fmt.Sprintf(`usage: consul-ecs config show [<path>]
+
+Parses the consul-ecs config in the file at <path>, or in the %s
+environment variable if no path is given, and prints the effective config as
+JSON. The effective config includes the defaults that consul-ecs applies, such
+as the proxy and gateway ports and the TLS settings inherited from
+consulServers.defaults. Defaults that depend on the task, such as the node
+name, are not shown.
+
+Values whose key names a token, password or secret, such as a token inlined in
+proxy.config, are redacted. Secret ARNs are shown.
+
+`, config.ConfigEnvironmentVariable) + buf.String()
+}
@@ -0,0 +1,121 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package configshow
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-ecs/testutil"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	serviceConfig := `{"bootstrapDir": "/consul/", "consulServers": {"hosts": "consul.dc1"}, "service": {"port": 8080}}`
+	gatewayConfig := `{"bootstrapDir": "/consul/", "consulServers": {"hosts": "consul.dc1"}, "gateway": {"kind": "mesh-gateway"}}`
+
+	cases := map[string]struct {
+		fileContents string
+		envContents  string
+		args         []string
+		expCode      int
+		expConfig    map[string]interface{}
+		expErrors    []string
+	}{
+		"service defaults": {
+			fileContents: serviceConfig,
+			expConfig: map[string]interface{}{
+				"proxy": map[string]interface{}{
+					"publicListenerPort": float64(20000),
+					"healthCheckPort":    float64(22000),
+				},
+			},
+		},
+		"gateway defaults from the env var": {
+			envContents: gatewayConfig,
+			expConfig: map[string]interface{}{
+				"gateway": map[string]interface{}{
+					"kind":            "mesh-gateway",
+					"lanAddress":      map[string]interface{}{"port": float64(8443)},
+					"healthCheckPort": float64(22000),
+					"sourceTag":       "consul-ecs",
+				},
+			},
+		},
+		"invalid config": {
+			fileContents: `{"bootstrapDir": 1, "consulServers": {"hosts": "consul.dc1"}}`,
+			expCode:      1,
+			expErrors: []string{
+				"invalid config:",
+				"  * bootstrapDir: Invalid type. Expected: string, given: integer",
+			},
+		},
+		"too many arguments": {
+			args:      []string{"a.json", "b.json"},
+			expCode:   1,
+			expErrors: []string{"unexpected argument: b.json"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := c.args
+			if c.fileContents != "" {
+				path := filepath.Join(testutil.TempDir(t), "config.json")
+				require.NoError(t, os.WriteFile(path, []byte(c.fileContents), 0600))
+				args = append(args, path)
+			}
+			if c.envContents != "" {
+				t.Setenv("CONSUL_ECS_CONFIG_JSON", c.envContents)
+			}
+
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			require.Equal(t, c.expCode, cmd.Run(args), ui.ErrorWriter.String())
+			for _, e := range c.expErrors {
+				require.Contains(t, ui.ErrorWriter.String(), e)
+			}
+			if c.expConfig == nil {
+				return
+			}
+
+			var output map[string]interface{}
+			require.NoError(t, json.Unmarshal(ui.OutputWriter.Bytes(), &output))
+			for key, exp := range c.expConfig {
+				require.Equal(t, exp, output[key])
+			}
+			consulServers := output["consulServers"].(map[string]interface{})
+			require.Equal(t, float64(8503), consulServers["grpc"].(map[string]interface{})["port"])
+			require.Equal(t, "30s", consulServers["httpTimeout"])
+		})
+	}
+}
+
+func TestRunRedactsSecrets(t *testing.T) {
+	config := `{
+		"bootstrapDir": "/consul/",
+		"consulServers": {
+			"hosts": "consul.dc1",
+			"grpc": {"caCertSecretArn": "arn:aws:secretsmanager:us-east-1:123456789:secret:consul-ca-cert"}
+		},
+		"service": {"port": 8080, "meta": {"api-token": "meta-token", "team": "web"}},
+		"proxy": {"config": {"auth_token": "proxy-token", "tracing": {"client_secret": "tracing-secret"}}}
+	}`
+	path := filepath.Join(testutil.TempDir(t), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui}
+	require.Equal(t, 0, cmd.Run([]string{path}), ui.ErrorWriter.String())
+
+	output := ui.OutputWriter.String()
+	for _, secret := range []string{"meta-token", "proxy-token", "tracing-secret"} {
+		require.NotContains(t, output, secret)
+	}
+	require.Contains(t, output, `"api-token": "<redacted>"`)
+	require.Contains(t, output, `"team": "web"`)
+	require.Contains(t, output, `"caCertSecretArn": "arn:aws:secretsmanager:us-east-1:123456789:secret:consul-ca-cert"`)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package configvalidate
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-ecs/config"
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/cli"
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flagSet    *flag.FlagSet
+	flagStrict bool
+	once       sync.Once
+}
+
+const flagStrict = "strict"
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.BoolVar(&c.flagStrict, flagStrict, false,
+		"Treat warnings about likely config mistakes as errors.")
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flagSet.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("invalid flags: %s", err))
+		return 1
+	}
+
+	args = c.flagSet.Args()
+	if len(args) > 1 {
+		c.UI.Error(fmt.Sprintf("unexpected argument: %v", args[1]))
+		return 1
+	}
+	var path string
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		c.UI.Error("invalid config:")
+		var merr *multierror.Error
+		if errors.As(err, &merr) {
+			for _, e := range merr.Errors {
+				c.UI.Error(fmt.Sprintf("  * %s", e))
+			}
+		} else {
+			c.UI.Error(fmt.Sprintf("  * %s", err))
+		}
+		return 1
+	}
+
+	warnings := cfg.Warnings()
+	for _, w := range warnings {
+		c.UI.Warn(fmt.Sprintf("warning: %s", w))
+	}
+	if c.flagStrict && len(warnings) > 0 {
+		c.UI.Error("invalid config: warnings are errors in strict mode")
+		return 1
+	}
+
+	c.UI.Output("config is valid")
+	return 0
+}
+
+func (c *Command) Synopsis() string {
+	return "Validates a consul-ecs config"
+}
+
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+
+	var buf strings.Builder
+	c.flagSet.SetOutput(&buf)
+	c.flagSet.PrintDefaults()
+	return fmt.Sprintf(`usage: consul-ecs config validate [options] [<path>]
+
+Validates the consul-ecs config in the file at <path>, or in the %s
+environment variable if no path is given. The config is validated against the
+JSON schema and parsed, without contacting ECS or Consul. All validation errors
+are printed with the path of the invalid field. Likely mistakes that do not
+make the config invalid are printed as warnings.
+
+An exit code of 0 is returned if the config is valid.
+
+`, config.ConfigEnvironmentVariable) + buf.String()
+}
@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package configvalidate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-ecs/testutil"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	validConfig := `{"bootstrapDir": "/consul/", "consulServers": {"hosts": "consul.dc1"}, "service": {"port": 8080}}`
+	invalidConfig := `{"bootstrapDir": 1, "consulServers": {"hosts": "consul.dc1"}, "service": {"port": "8080"}, "extra": true}`
+	portlessConfig := `{"bootstrapDir": "/consul/", "consulServers": {"hosts": "consul.dc1"}, "service": {"port": 0},
+		"proxy": {"upstreams": [{"destinationName": "backend", "localBindPort": 1234}]}}`
+	portlessWarning := "warning: service.port is not set but proxy.upstreams are defined"
+
+	cases := map[string]struct {
+		fileContents string
+		envContents  string
+		args         []string
+		expCode      int
+		expOutput    string
+		expErrors    []string
+	}{
+		"valid file": {
+			fileContents: validConfig,
+			expOutput:    "config is valid",
+		},
+		"valid env var": {
+			envContents: validConfig,
+			expOutput:   "config is valid",
+		},
+		"invalid file reports all errors": {
+			fileContents: invalidConfig,
+			expCode:      1,
+			expErrors: []string{
+				"invalid config:",
+				"  * bootstrapDir: Invalid type. Expected: string, given: integer",
+				"  * service.port: Invalid type. Expected: integer, given: string",
+				"  * (root): Additional property extra is not allowed",
+			},
+		},
+		"missing file": {
+			args:      []string{"does-not-exist.json"},
+			expCode:   1,
+			expErrors: []string{"no such file or directory"},
+		},
+		"env var unset": {
+			expCode:   1,
+			expErrors: []string{`"CONSUL_ECS_CONFIG_JSON" isn't populated`},
+		},
+		"portless service with upstreams warns": {
+			fileContents: portlessConfig,
+			expOutput:    "config is valid",
+			expErrors:    []string{portlessWarning},
+		},
+		"strict mode fails on warnings": {
+			fileContents: portlessConfig,
+			args:         []string{"-strict"},
+			expCode:      1,
+			expErrors:    []string{portlessWarning, "invalid config: warnings are errors in strict mode"},
+		},
+		"strict mode passes without warnings": {
+			fileContents: validConfig,
+			args:         []string{"-strict"},
+			expOutput:    "config is valid",
+		},
+		"invalid flag": {
+			args:      []string{"-unknown"},
+			expCode:   1,
+			expErrors: []string{"invalid flags: flag provided but not defined: -unknown"},
+		},
+		"too many arguments": {
+			args:      []string{"a.json", "b.json"},
+			expCode:   1,
+			expErrors: []string{"unexpected argument: b.json"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := c.args
+			if c.fileContents != "" {
+				path := filepath.Join(testutil.TempDir(t), "config.json")
+				require.NoError(t, os.WriteFile(path, []byte(c.fileContents), 0600))
+				args = append(args, path)
+			}
+			if c.envContents != "" {
+				t.Setenv("CONSUL_ECS_CONFIG_JSON", c.envContents)
+			}
+
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			require.Equal(t, c.expCode, cmd.Run(args))
+			require.Contains(t, ui.OutputWriter.String(), c.expOutput)
+			for _, e := range c.expErrors {
+				require.Contains(t, ui.ErrorWriter.String(), e)
+			}
+		})
+	}
+}
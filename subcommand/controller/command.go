@@ -6,14 +6,19 @@ package controller
 import (
 	"bytes"
 	"context"
+	"flag"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/hashicorp/consul-ecs/awsutil"
@@ -25,6 +30,8 @@ import (
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/mapstructure"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -49,6 +56,23 @@ const (
 	// to this role via terraform.
 	terminatingGatewayRoleName        = "consul-ecs-terminating-gateway-role"
 	terminatingGatewayRoleDescription = "Terminating Gateway Role for ECS"
+
+	flagReconcileInterval = "reconcile-interval"
+	flagMetricsAddr       = "metrics-addr"
+	flagLeaderElection    = "leader-election"
+	flagExcludeFamilies   = "exclude-families"
+	flagAuditLog          = "audit-log"
+
+	// leaderLockKeyPrefix is the prefix of the Consul KV key that controllers
+	// for the same cluster lock to elect a leader. The cluster ARN is appended.
+	leaderLockKeyPrefix = "consul-ecs/controller-leader/"
+	// leaderLockSessionTTL bounds how long a lock held by a controller that
+	// stopped without releasing it blocks the other controllers.
+	leaderLockSessionTTL = "15s"
+
+	// minReconcileInterval bounds how often the controller lists all ECS tasks
+	// and Consul tokens, to avoid overloading the AWS and Consul APIs.
+	minReconcileInterval = 5 * time.Second
 )
 
 type Command struct {
@@ -59,6 +83,15 @@ type Command struct {
 	once   sync.Once
 	ctx    context.Context
 
+	flagSet               *flag.FlagSet
+	flagReconcileInterval time.Duration
+	flagMetricsAddr       string
+	flagLeaderElection    bool
+	flagExcludeFamilies   string
+	flagAuditLog          string
+
+	excludeFamilies []*regexp.Regexp
+
 	logging.LogOpts
 
 	watcher *discovery.Watcher
@@ -66,16 +99,51 @@ type Command struct {
 
 func (c *Command) init() {
 	c.ctx = context.Background()
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.DurationVar(&c.flagReconcileInterval, flagReconcileInterval, controller.DefaultPollingInterval,
+		fmt.Sprintf("Interval between reconciles of the ECS tasks and Consul tokens. A jitter of up to 10%% is applied to each interval. Must be at least %s.", minReconcileInterval))
+	c.flagSet.StringVar(&c.flagMetricsAddr, flagMetricsAddr, "",
+		"Address, such as `:9102`, to serve Prometheus metrics for the reconcile loop on at `/metrics`. Metrics are disabled if empty.")
+	c.flagSet.BoolVar(&c.flagLeaderElection, flagLeaderElection, false,
+		"Elect a leader with a Consul lock, so that only one of several controllers for the cluster reconciles at a time. "+
+			"The others stand by and take over if the leader stops. The Consul token must have `session:write` and `key:write` "+
+			"permissions on the `"+leaderLockKeyPrefix+"` key prefix.")
+	c.flagSet.StringVar(&c.flagExcludeFamilies, flagExcludeFamilies, "",
+		"Comma-separated task definition families, such as `legacy-app,batch-.*`, whose tasks the controller does not manage "+
+			"even if they have the mesh tag. Each entry is a regular expression that must match the whole family name. "+
+			"The tokens and services of these families are left untouched, including after their tasks stop. "+
+			"Tokens and services registered by older versions of mesh-init do not record their family and are still cleaned up.")
+	c.flagSet.StringVar(&c.flagAuditLog, flagAuditLog, "",
+		"Path of a file to append an audit event to, as a JSON line, for each ACL token that the controller deletes. "+
+			"Use `-` to write the events to stdout, separately from the logs on stderr. Events include the accessor ID but never the secret ID. "+
+			"Auditing is disabled if empty.")
 }
 
 func (c *Command) Run(args []string) int {
 	c.once.Do(c.init)
 
-	if len(args) > 0 {
-		c.UI.Error(fmt.Sprintf("unexpected argument: %v", args[0]))
+	if err := c.flagSet.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("invalid flags: %s", err))
+		return 1
+	}
+
+	if len(c.flagSet.Args()) > 0 {
+		c.UI.Error(fmt.Sprintf("unexpected argument: %v", c.flagSet.Args()[0]))
+		return 1
+	}
+
+	if c.flagReconcileInterval < minReconcileInterval {
+		c.UI.Error(fmt.Sprintf("invalid flags: -%s must be at least %s", flagReconcileInterval, minReconcileInterval))
 		return 1
 	}
 
+	excludeFamilies, err := controller.CompileFamilyPatterns(c.flagExcludeFamilies)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("invalid flags: -%s: %s", flagExcludeFamilies, err))
+		return 1
+	}
+	c.excludeFamilies = excludeFamilies
+
 	config, err := config.FromEnv()
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("invalid config: %s", err))
@@ -94,7 +162,7 @@ func (c *Command) Run(args []string) int {
 }
 
 func (c *Command) run() error {
-	ecsMeta, err := awsutil.ECSTaskMetadata()
+	ecsMeta, err := awsutil.CachedECSTaskMetadata()
 	if err != nil {
 		return err
 	}
@@ -134,17 +202,47 @@ func (c *Command) run() error {
 		return err
 	}
 
+	auditLog, closeAuditLog, err := c.openAuditLog(ecsMeta)
+	if err != nil {
+		return err
+	}
+	defer closeAuditLog()
+
+	var metrics *controller.Metrics
+	if c.flagMetricsAddr != "" {
+		metrics, err = c.serveMetrics()
+		if err != nil {
+			return err
+		}
+	}
+
 	taskStateLister := &controller.TaskStateLister{
-		ECSClient:           ecsClient,
-		SetupConsulClientFn: c.setupConsulAPIClient,
-		ClusterARN:          clusterArn,
-		Partition:           c.config.Controller.Partition,
-		Log:                 c.log,
+		ECSClient:                ecsClient,
+		SetupConsulClientFn:      c.setupConsulAPIClient,
+		ClusterARN:               clusterArn,
+		Partition:                c.config.Controller.Partition,
+		RequiredTags:             c.config.Controller.RequiredTags,
+		ExcludeFamilies:          c.excludeFamilies,
+		DescribeTasksConcurrency: c.config.Controller.DescribeTasksConcurrency,
+		Throttling:               &controller.Throttling{},
+		AuditLog:                 auditLog,
+		Log:                      c.log,
+		Metrics:                  metrics,
 	}
 	ctrl := controller.Controller{
 		Resources:       taskStateLister,
-		PollingInterval: controller.DefaultPollingInterval,
+		PollingInterval: c.flagReconcileInterval,
 		Log:             c.log,
+		Metrics:         metrics,
+	}
+	if c.flagLeaderElection {
+		ctrl.NewLock = func() (controller.Locker, error) {
+			consulClient, err := c.setupLockConsulAPIClient()
+			if err != nil {
+				return nil, err
+			}
+			return consulClient.LockOpts(leaderLockOptions(clusterArn))
+		}
 	}
 
 	ctrl.Run(c.ctx)
@@ -152,15 +250,95 @@ func (c *Command) run() error {
 	return nil
 }
 
+// leaderLockOptions returns the options of the Consul lock that the
+// controllers for the cluster hold while reconciling.
+func leaderLockOptions(clusterARN string) *api.LockOptions {
+	return &api.LockOptions{
+		Key:         leaderLockKeyPrefix + clusterARN,
+		SessionName: "consul-ecs-controller",
+		SessionTTL:  leaderLockSessionTTL,
+		// Ride out brief Consul unavailability without losing the lock.
+		MonitorRetries: 3,
+	}
+}
+
+// openAuditLog opens the -audit-log file, or stdout for `-`. The controller's
+// task ARN is recorded as the actor of each event. It returns a nil AuditLog
+// if auditing is disabled.
+func (c *Command) openAuditLog(ecsMeta awsutil.ECSTaskMeta) (*controller.AuditLog, func(), error) {
+	switch c.flagAuditLog {
+	case "":
+		return nil, func() {}, nil
+	case "-":
+		return controller.NewAuditLog(os.Stdout, ecsMeta.TaskARN), func() {}, nil
+	}
+	f, err := os.OpenFile(c.flagAuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	return controller.NewAuditLog(f, ecsMeta.TaskARN), func() { f.Close() }, nil
+}
+
+// serveMetrics registers the controller metrics and serves them at
+// /metrics on the -metrics-addr address.
+func (c *Command) serveMetrics() (*controller.Metrics, error) {
+	reg := prometheus.NewRegistry()
+	metrics, err := controller.NewMetrics(reg)
+	if err != nil {
+		return nil, fmt.Errorf("registering metrics: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", c.flagMetricsAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on metrics address %s: %w", c.flagMetricsAddr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			c.log.Error("serving metrics", "err", err)
+		}
+	}()
+	c.log.Info("serving metrics", "addr", listener.Addr().String())
+	return metrics, nil
+}
+
 func (c *Command) Synopsis() string {
 	return "ECS controller"
 }
 
 func (c *Command) Help() string {
-	return ""
+	c.once.Do(c.init)
+
+	var buf strings.Builder
+	c.flagSet.SetOutput(&buf)
+	c.flagSet.PrintDefaults()
+	return "Usage: consul-ecs controller [options]\n\n" + buf.String()
 }
 
 func (c *Command) setupConsulAPIClient() (*api.Client, error) {
+	cfg, err := c.consulClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.config.NewConsulAPIClient(cfg)
+}
+
+// setupLockConsulAPIClient returns the Consul API client of the leader lock.
+// Acquiring and monitoring the lock use blocking queries that wait on the
+// server for longer than consulServers.httpTimeout, so the client does not
+// apply the HTTP timeout.
+func (c *Command) setupLockConsulAPIClient() (*api.Client, error) {
+	cfg, err := c.consulClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.config.NewBlockingConsulAPIClient(cfg)
+}
+
+func (c *Command) consulClientConfig() (*api.Config, error) {
 	state, err := c.watcher.State()
 	if err != nil {
 		return nil, fmt.Errorf("unable to fetch consul server watcher state: %w", err)
@@ -173,8 +351,7 @@ func (c *Command) setupConsulAPIClient() (*api.Client, error) {
 	if token != "" {
 		cfg.Token = token
 	}
-
-	return api.NewClient(cfg)
+	return cfg, nil
 }
 
 // upsertConsulResources creates the necessary resources in Consul if they do not exist.
@@ -6,6 +6,8 @@ package controller
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
 	"testing"
 	"time"
@@ -80,6 +82,75 @@ type iamAuthTestCase struct {
 	partitionsEnabled  bool
 }
 
+func TestFlagValidation(t *testing.T) {
+	cases := map[string]struct {
+		args   []string
+		expErr string
+	}{
+		"unexpected argument": {
+			args:   []string{"some-arg"},
+			expErr: "unexpected argument: some-arg",
+		},
+		"invalid reconcile interval": {
+			args:   []string{"-reconcile-interval", "invalid"},
+			expErr: "invalid flags:",
+		},
+		"reconcile interval below minimum": {
+			args:   []string{"-reconcile-interval", "1s"},
+			expErr: "invalid flags: -reconcile-interval must be at least 5s",
+		},
+		"invalid exclude families": {
+			args:   []string{"-exclude-families", "app,batch-("},
+			expErr: `invalid flags: -exclude-families: invalid family pattern "batch-("`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			require.Equal(t, 1, cmd.Run(c.args))
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestLeaderLockOptions(t *testing.T) {
+	clusterARN := "arn:aws:ecs:us-east-1:123456789:cluster/test-cluster"
+	opts := leaderLockOptions(clusterARN)
+	require.Equal(t, "consul-ecs/controller-leader/"+clusterARN, opts.Key)
+	require.Equal(t, "15s", opts.SessionTTL)
+
+	client, err := api.NewClient(api.DefaultConfig())
+	require.NoError(t, err)
+	_, err = client.LockOpts(opts)
+	require.NoError(t, err)
+}
+
+func TestOpenAuditLog(t *testing.T) {
+	ecsMeta := awsutil.ECSTaskMeta{TaskARN: "arn:aws:ecs:us-east-1:123456789:task/test-cluster/abcdef"}
+
+	cmd := Command{}
+	auditLog, closeAuditLog, err := cmd.openAuditLog(ecsMeta)
+	require.NoError(t, err)
+	require.Nil(t, auditLog)
+	closeAuditLog()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cmd.flagAuditLog = path
+	auditLog, closeAuditLog, err = cmd.openAuditLog(ecsMeta)
+	require.NoError(t, err)
+	require.NotNil(t, auditLog)
+	closeAuditLog()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	cmd.flagAuditLog = filepath.Join(t.TempDir(), "missing", "audit.log")
+	_, _, err = cmd.openAuditLog(ecsMeta)
+	require.ErrorContains(t, err, "opening audit log")
+}
+
 func TestUpsertConsulResources(t *testing.T) {
 	testUpsertConsulResources(t, map[string]iamAuthTestCase{
 		"recreate no ACL resources": {},
@@ -17,7 +17,7 @@ import (
 // and proxy registrations
 func (c *Command) fetchHealthChecks(consulClient *api.Client, taskMeta awsutil.ECSTaskMeta) (map[string]*api.HealthCheck, error) {
 	serviceName := c.constructServiceName(taskMeta.Family)
-	serviceID := makeServiceID(serviceName, taskMeta.TaskID())
+	serviceID := makeServiceID(serviceName, c.config.Mesh.GetServiceIDSuffix(taskMeta))
 	proxySvcID, proxySvcName := makeProxySvcIDAndName(serviceID, serviceName)
 
 	healthCheckMap := make(map[string]*api.HealthCheck)
@@ -43,7 +43,7 @@ func (c *Command) fetchHealthChecks(consulClient *api.Client, taskMeta awsutil.E
 		healthCheckMap[check.CheckID] = check
 	}
 
-	if c.config.IsGateway() {
+	if c.config.IsGateway() || !c.config.SidecarEnabled() {
 		return healthCheckMap, nil
 	}
 
@@ -63,19 +63,19 @@ func (c *Command) fetchHealthChecks(consulClient *api.Client, taskMeta awsutil.E
 }
 
 // setChecksCritical sets checks for all of the containers to critical
-func (c *Command) setChecksCritical(consulClient *api.Client, taskMeta awsutil.ECSTaskMeta, clusterARN string, parsedContainerNames []string) error {
+func (c *Command) setChecksCritical(consulClient *api.Client, taskMeta awsutil.ECSTaskMeta, nodeName string, parsedContainerNames []string) error {
 	var result error
 
-	taskID := taskMeta.TaskID()
+	idSuffix := c.config.Mesh.GetServiceIDSuffix(taskMeta)
 	serviceName := c.constructServiceName(taskMeta.Family)
 
 	for _, containerName := range parsedContainerNames {
 		var err error
 		if containerName == config.ConsulDataplaneContainerName {
-			err = c.handleHealthForDataplaneContainer(consulClient, taskID, serviceName, clusterARN, containerName, ecs.HealthStatusUnhealthy)
+			err = c.handleHealthForDataplaneContainer(consulClient, idSuffix, serviceName, nodeName, containerName, ecs.HealthStatusUnhealthy)
 		} else {
-			checkID := constructCheckID(makeServiceID(serviceName, taskID), containerName)
-			err = c.updateConsulHealthStatus(consulClient, checkID, clusterARN, ecs.HealthStatusUnhealthy)
+			checkID := constructCheckID(makeServiceID(serviceName, idSuffix), containerName)
+			err = c.updateConsulHealthStatus(consulClient, checkID, nodeName, ecs.HealthStatusUnhealthy)
 		}
 
 		if err == nil {
@@ -98,7 +98,7 @@ func (c *Command) setChecksCritical(consulClient *api.Client, taskMeta awsutil.E
 // the last invocation of this function.
 func (c *Command) syncChecks(consulClient *api.Client,
 	currentStatuses map[string]string,
-	clusterARN string,
+	nodeName string,
 	parsedContainerNames []string) map[string]string {
 	// Fetch task metadata to get latest health of the containers
 	taskMeta, err := awsutil.ECSTaskMetadata()
@@ -108,19 +108,20 @@ func (c *Command) syncChecks(consulClient *api.Client,
 	}
 
 	serviceName := c.constructServiceName(taskMeta.Family)
+	idSuffix := c.config.Mesh.GetServiceIDSuffix(taskMeta)
 
 	containersToSync, missingContainers := findContainersToSync(parsedContainerNames, taskMeta)
 
 	// Mark the Consul health status as critical for missing containers
 	for _, name := range missingContainers {
-		checkID := constructCheckID(makeServiceID(serviceName, taskMeta.TaskID()), name)
+		checkID := constructCheckID(makeServiceID(serviceName, idSuffix), name)
 		c.log.Debug("marking container as unhealthy since it wasn't found in the task metadata", "name", name)
 
 		var err error
 		if name == config.ConsulDataplaneContainerName {
-			err = c.handleHealthForDataplaneContainer(consulClient, taskMeta.TaskID(), serviceName, clusterARN, name, ecs.HealthStatusUnhealthy)
+			err = c.handleHealthForDataplaneContainer(consulClient, idSuffix, serviceName, nodeName, name, ecs.HealthStatusUnhealthy)
 		} else {
-			err = c.updateConsulHealthStatus(consulClient, checkID, clusterARN, ecs.HealthStatusUnhealthy)
+			err = c.updateConsulHealthStatus(consulClient, checkID, nodeName, ecs.HealthStatusUnhealthy)
 		}
 
 		if err != nil {
@@ -143,10 +144,10 @@ func (c *Command) syncChecks(consulClient *api.Client,
 		if container.Health.Status != previousStatus {
 			var err error
 			if container.Name == config.ConsulDataplaneContainerName {
-				err = c.handleHealthForDataplaneContainer(consulClient, taskMeta.TaskID(), serviceName, clusterARN, container.Name, container.Health.Status)
+				err = c.handleHealthForDataplaneContainer(consulClient, idSuffix, serviceName, nodeName, container.Name, container.Health.Status)
 			} else {
-				checkID := constructCheckID(makeServiceID(serviceName, taskMeta.TaskID()), container.Name)
-				err = c.updateConsulHealthStatus(consulClient, checkID, clusterARN, container.Health.Status)
+				checkID := constructCheckID(makeServiceID(serviceName, idSuffix), container.Name)
+				err = c.updateConsulHealthStatus(consulClient, checkID, nodeName, container.Health.Status)
 			}
 
 			if err != nil {
@@ -170,26 +171,26 @@ func (c *Command) syncChecks(consulClient *api.Client,
 // the health of consul-dataplane container. We register two checks (one for the service
 // and the other for proxy) when registering a typical service to the catalog. Updates
 // should also happen twice in such cases.
-func (c *Command) handleHealthForDataplaneContainer(consulClient *api.Client, taskID, serviceName, clusterARN, containerName, ecsHealthStatus string) error {
+func (c *Command) handleHealthForDataplaneContainer(consulClient *api.Client, idSuffix, serviceName, nodeName, containerName, ecsHealthStatus string) error {
 	var checkID string
-	serviceID := makeServiceID(serviceName, taskID)
+	serviceID := makeServiceID(serviceName, idSuffix)
 	if c.config.IsGateway() {
 		checkID = constructCheckID(serviceID, containerName)
-		return c.updateConsulHealthStatus(consulClient, checkID, clusterARN, ecsHealthStatus)
+		return c.updateConsulHealthStatus(consulClient, checkID, nodeName, ecsHealthStatus)
 	}
 
 	checkID = constructCheckID(serviceID, containerName)
-	err := c.updateConsulHealthStatus(consulClient, checkID, clusterARN, ecsHealthStatus)
+	err := c.updateConsulHealthStatus(consulClient, checkID, nodeName, ecsHealthStatus)
 	if err != nil {
 		return err
 	}
 
 	proxySvcID, _ := makeProxySvcIDAndName(serviceID, "")
 	checkID = constructCheckID(proxySvcID, containerName)
-	return c.updateConsulHealthStatus(consulClient, checkID, clusterARN, ecsHealthStatus)
+	return c.updateConsulHealthStatus(consulClient, checkID, nodeName, ecsHealthStatus)
 }
 
-func (c *Command) updateConsulHealthStatus(consulClient *api.Client, checkID string, clusterARN string, ecsHealthStatus string) error {
+func (c *Command) updateConsulHealthStatus(consulClient *api.Client, checkID string, nodeName string, ecsHealthStatus string) error {
 	consulHealthStatus := ecsHealthToConsulHealth(ecsHealthStatus)
 
 	check, ok := c.checks[checkID]
@@ -202,7 +203,7 @@ func (c *Command) updateConsulHealthStatus(consulClient *api.Client, checkID str
 	c.checks[checkID] = check
 
 	updateCheckReq := &api.CatalogRegistration{
-		Node:           clusterARN,
+		Node:           nodeName,
 		SkipNodeUpdate: true,
 		Checks:         api.HealthChecks{check},
 	}
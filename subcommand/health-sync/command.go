@@ -16,6 +16,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/hashicorp/consul-ecs/awsutil"
 	"github.com/hashicorp/consul-ecs/config"
 	"github.com/hashicorp/consul-ecs/logging"
@@ -26,12 +27,6 @@ import (
 	"github.com/mitchellh/cli"
 )
 
-const (
-	// syncChecksInterval is how often we poll the container health endpoint.
-	// The rate limit is about 40 per second, so 1 second polling seems reasonable.
-	syncChecksInterval = 1 * time.Second
-)
-
 type Command struct {
 	UI     cli.Ui
 	config *config.Config
@@ -105,6 +100,18 @@ func (c *Command) realRun() error {
 	if err != nil {
 		return err
 	}
+	nodeName := c.config.Mesh.GetNodeName(clusterARN)
+
+	if c.config.ConsulServers.GRPC.CaCertSecretARN != "" {
+		clientSession, err := awsutil.NewSession(taskMeta, "health-sync")
+		if err != nil {
+			return err
+		}
+		err = c.config.LoadGRPCCACertSecret(secretsmanager.New(clientSession))
+		if err != nil {
+			return err
+		}
+	}
 
 	serverConnMgrCfg, err := c.config.ConsulServerConnMgrConfig(taskMeta)
 	if err != nil {
@@ -134,8 +141,10 @@ func (c *Command) realRun() error {
 	}
 
 	var healthSyncContainers []string
-	healthSyncContainers = append(healthSyncContainers, c.config.HealthSyncContainers...)
-	healthSyncContainers = append(healthSyncContainers, config.ConsulDataplaneContainerName)
+	healthSyncContainers = append(healthSyncContainers, c.config.GetHealthSyncContainers(taskMeta)...)
+	if c.config.IsGateway() || c.config.SidecarEnabled() {
+		healthSyncContainers = append(healthSyncContainers, config.ConsulDataplaneContainerName)
+	}
 	currentHealthStatuses := make(map[string]string)
 
 	c.checks, err = c.fetchHealthChecks(consulClient, taskMeta)
@@ -148,10 +157,11 @@ func (c *Command) realRun() error {
 		<-c.proceedChan
 	}
 
+	syncChecksInterval := c.config.Mesh.GetHealthSyncInterval()
 	for {
 		select {
 		case <-time.After(syncChecksInterval):
-			currentHealthStatuses = c.syncChecks(consulClient, currentHealthStatuses, clusterARN, healthSyncContainers)
+			currentHealthStatuses = c.syncChecks(consulClient, currentHealthStatuses, nodeName, healthSyncContainers)
 		case watcherState := <-c.watcherCh:
 			c.log.Info("Switching to Consul server", "address", watcherState.Address.String())
 			client, err := c.setupConsulAPIClient(watcherState)
@@ -162,7 +172,7 @@ func (c *Command) realRun() error {
 			}
 		case <-c.sigs:
 			c.log.Info("Received SIGTERM. Beginning graceful shutdown by first marking all checks as critical.")
-			err := c.setChecksCritical(consulClient, taskMeta, clusterARN, healthSyncContainers)
+			err := c.setChecksCritical(consulClient, taskMeta, nodeName, healthSyncContainers)
 			if err != nil {
 				c.log.Error("Error marking the status of checks as critical: %s", err.Error())
 			}
@@ -171,13 +181,13 @@ func (c *Command) realRun() error {
 			c.log.Info("Dataplane has successfully shutdown. Deregistering services and terminating health-sync")
 
 			if c.config.IsGateway() {
-				err = c.deregisterGatewayProxy(consulClient, taskMeta, clusterARN)
+				err = c.deregisterGatewayProxy(consulClient, taskMeta, nodeName)
 				if err != nil {
 					c.log.Error("error deregistering gateway %s", err.Error())
 					result = multierror.Append(result, err)
 				}
 			} else {
-				err = c.deregisterServiceAndProxy(consulClient, taskMeta, clusterARN)
+				err = c.deregisterServiceAndProxy(consulClient, taskMeta, nodeName)
 				if err != nil {
 					c.log.Error("error deregistering service and proxy %s", err.Error())
 					result = multierror.Append(result, err)
@@ -223,27 +233,32 @@ func (c *Command) setupConsulAPIClient(state discovery.State) (*api.Client, erro
 	cfg.Address = net.JoinHostPort(state.Address.IP.String(), strconv.FormatInt(int64(c.config.ConsulServers.HTTP.Port), 10))
 	if state.Token != "" {
 		cfg.Token = state.Token
+	} else {
+		cfg.Token = config.GetConsulToken()
 	}
 
-	return api.NewClient(cfg)
+	return c.config.NewConsulAPIClient(cfg)
 }
 
-func (c *Command) deregisterServiceAndProxy(consulClient *api.Client, taskMeta awsutil.ECSTaskMeta, clusterARN string) error {
+func (c *Command) deregisterServiceAndProxy(consulClient *api.Client, taskMeta awsutil.ECSTaskMeta, nodeName string) error {
 	var result error
 	serviceName := c.constructServiceName(taskMeta.Family)
-	taskID := taskMeta.TaskID()
-	serviceID := makeServiceID(serviceName, taskID)
+	serviceID := makeServiceID(serviceName, c.config.Mesh.GetServiceIDSuffix(taskMeta))
 
 	service := c.config.Service.ToConsulType()
 
-	err := deregisterConsulService(consulClient, serviceID, service.Namespace, service.Partition, clusterARN)
+	err := deregisterConsulService(consulClient, serviceID, service.Namespace, service.Partition, nodeName)
 	if err != nil {
 		result = multierror.Append(result, err)
 	}
 
+	if !c.config.SidecarEnabled() {
+		return result
+	}
+
 	// Proxy deregistration
 	proxySvcID, _ := makeProxySvcIDAndName(serviceID, serviceName)
-	err = deregisterConsulService(consulClient, proxySvcID, service.Namespace, service.Partition, clusterARN)
+	err = deregisterConsulService(consulClient, proxySvcID, service.Namespace, service.Partition, nodeName)
 	if err != nil {
 		result = multierror.Append(result, err)
 	}
@@ -251,14 +266,13 @@ func (c *Command) deregisterServiceAndProxy(consulClient *api.Client, taskMeta a
 	return result
 }
 
-func (c *Command) deregisterGatewayProxy(consulClient *api.Client, taskMeta awsutil.ECSTaskMeta, clusterARN string) error {
+func (c *Command) deregisterGatewayProxy(consulClient *api.Client, taskMeta awsutil.ECSTaskMeta, nodeName string) error {
 	gatewaySvcName := c.constructServiceName(taskMeta.Family)
-	taskID := taskMeta.TaskID()
-	gatewaySvcID := makeServiceID(gatewaySvcName, taskID)
+	gatewaySvcID := makeServiceID(gatewaySvcName, c.config.Mesh.GetServiceIDSuffix(taskMeta))
 
 	gatewaySvc := c.config.Gateway.ToConsulType()
 
-	return deregisterConsulService(consulClient, gatewaySvcID, gatewaySvc.Namespace, gatewaySvc.Partition, clusterARN)
+	return deregisterConsulService(consulClient, gatewaySvcID, gatewaySvc.Namespace, gatewaySvc.Partition, nodeName)
 }
 
 func (c *Command) constructServiceName(family string) string {
@@ -275,8 +289,8 @@ func (c *Command) constructServiceName(family string) string {
 	return configName
 }
 
-func makeServiceID(serviceName, taskID string) string {
-	return fmt.Sprintf("%s-%s", serviceName, taskID)
+func makeServiceID(serviceName, idSuffix string) string {
+	return fmt.Sprintf("%s-%s", serviceName, idSuffix)
 }
 
 func makeProxySvcIDAndName(serviceID, serviceName string) (string, string) {
@@ -285,8 +285,11 @@ func TestRun(t *testing.T) {
 				containersToSync = append(containersToSync, name)
 			}
 			consulEcsConfig := config.Config{
-				LogLevel:             "DEBUG",
-				BootstrapDir:         envoyBootstrapDir,
+				LogLevel:     "DEBUG",
+				BootstrapDir: envoyBootstrapDir,
+				Mesh: config.Mesh{
+					HealthSyncInterval: config.Duration(1 * time.Second),
+				},
 				HealthSyncContainers: containersToSync,
 				ConsulLogin:          c.consulLogin,
 				ConsulServers: config.ConsulServers{
@@ -570,7 +573,10 @@ func TestRunGateways(t *testing.T) {
 			consulEcsConfig := config.Config{
 				LogLevel:     "DEBUG",
 				BootstrapDir: envoyBootstrapDir,
-				ConsulLogin:  c.consulLogin,
+				Mesh: config.Mesh{
+					HealthSyncInterval: config.Duration(1 * time.Second),
+				},
+				ConsulLogin: c.consulLogin,
 				ConsulServers: config.ConsulServers{
 					Hosts: "127.0.0.1",
 					GRPC: config.GRPCSettings{
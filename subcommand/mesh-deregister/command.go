@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshderegister
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-ecs/config"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/cli"
+)
+
+const (
+	flagNode      = "node"
+	flagSource    = "source"
+	flagPartition = "partition"
+	flagNamespace = "namespace"
+	flagConfirm   = "confirm"
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flagSet       *flag.FlagSet
+	flagNode      string
+	flagSource    string
+	flagPartition string
+	flagNamespace string
+	flagConfirm   bool
+	once          sync.Once
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.StringVar(&c.flagNode, flagNode, "",
+		"The Consul node to deregister services from, such as the ECS cluster ARN. Required.")
+	c.flagSet.StringVar(&c.flagSource, flagSource, config.DefaultSourceTag,
+		"Only services with this value in their `source` meta are deregistered.")
+	c.flagSet.StringVar(&c.flagPartition, flagPartition, "",
+		"The Consul admin partition of the services [Consul Enterprise].")
+	c.flagSet.StringVar(&c.flagNamespace, flagNamespace, "",
+		"The Consul namespace of the services [Consul Enterprise].")
+	c.flagSet.BoolVar(&c.flagConfirm, flagConfirm, false,
+		"Deregister the services. Without this flag, the services are listed but not deregistered.")
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flagSet.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("invalid flags: %s", err))
+		return 1
+	}
+
+	if len(c.flagSet.Args()) > 0 {
+		c.UI.Error(fmt.Sprintf("unexpected argument: %v", c.flagSet.Args()[0]))
+		return 1
+	}
+
+	if c.flagNode == "" {
+		c.UI.Error(fmt.Sprintf("invalid flags: -%s is required", flagNode))
+		return 1
+	}
+
+	// The Consul client is configured with the standard environment variables,
+	// such as CONSUL_HTTP_ADDR and CONSUL_HTTP_TOKEN.
+	consulClient, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("constructing Consul API client: %s", err))
+		return 1
+	}
+
+	if err := c.deregister(consulClient); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	return 0
+}
+
+// deregister deregisters the services on the node that were registered by
+// consul-ecs, including their sidecar proxies. Unless -confirm is set, the
+// services are only listed.
+func (c *Command) deregister(consulClient *api.Client) error {
+	services, err := c.managedServices(consulClient)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		c.UI.Output(fmt.Sprintf("no services with source %q found on node %s", c.flagSource, c.flagNode))
+		return nil
+	}
+
+	if !c.flagConfirm {
+		for _, svc := range services {
+			c.UI.Output(fmt.Sprintf("would deregister %s", svc.ID))
+		}
+		c.UI.Output(fmt.Sprintf("re-run with -%s to deregister %d services", flagConfirm, len(services)))
+		return nil
+	}
+
+	var result error
+	for _, svc := range services {
+		_, err := consulClient.Catalog().Deregister(&api.CatalogDeregistration{
+			Node:      c.flagNode,
+			ServiceID: svc.ID,
+			Namespace: svc.Namespace,
+			Partition: svc.Partition,
+		}, nil)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("deregistering %s: %w", svc.ID, err))
+			continue
+		}
+		c.UI.Output(fmt.Sprintf("deregistered %s", svc.ID))
+	}
+	return result
+}
+
+// managedServices returns the services on the node whose source meta matches
+// the -source flag, sorted by ID.
+func (c *Command) managedServices(consulClient *api.Client) ([]*api.AgentService, error) {
+	nodeServices, _, err := consulClient.Catalog().NodeServiceList(c.flagNode, &api.QueryOptions{
+		Namespace: c.flagNamespace,
+		Partition: c.flagPartition,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing services for node %s: %w", c.flagNode, err)
+	}
+	if nodeServices == nil {
+		return nil, nil
+	}
+
+	var services []*api.AgentService
+	for _, svc := range nodeServices.Services {
+		if svc.Meta["source"] == c.flagSource {
+			services = append(services, svc)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
+	return services, nil
+}
+
+func (c *Command) Synopsis() string {
+	return "Deregisters the services registered by consul-ecs on a node"
+}
+
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+
+	var buf strings.Builder
+	c.flagSet.SetOutput(&buf)
+	c.flagSet.PrintDefaults()
+	return `usage: consul-ecs mesh deregister [options] -node <node>
+
+Deregisters the services and sidecar proxies that consul-ecs registered on
+the given Consul node, such as those left in the catalog by force-killed
+tasks. Only services with a matching "source" meta are deregistered, so
+services registered by other tooling are left in place.
+
+Without -confirm, the services that would be deregistered are listed.
+
+The Consul API client is configured with the standard environment variables,
+such as CONSUL_HTTP_ADDR and CONSUL_HTTP_TOKEN.
+
+` + buf.String()
+}
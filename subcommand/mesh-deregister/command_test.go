@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshderegister
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-ecs/testutil"
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+const testNode = "arn:aws:ecs:us-east-1:123456789:cluster/test"
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		args   []string
+		expErr string
+	}{
+		"node is required": {
+			expErr: "invalid flags: -node is required",
+		},
+		"unexpected argument": {
+			args:   []string{"-node", testNode, "some-arg"},
+			expErr: "unexpected argument: some-arg",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			code := cmd.Run(c.args)
+			require.Equal(t, 1, code)
+			require.Equal(t, c.expErr+"\n", ui.ErrorWriter.String())
+		})
+	}
+}
+
+func TestDeregister(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		expServices []string
+	}{
+		"lists without confirm": {
+			args:        []string{"-node", testNode},
+			expServices: []string{"api-1", "api-1-sidecar-proxy", "other-1", "web-1", "web-1-sidecar-proxy"},
+		},
+		"deregisters managed services with confirm": {
+			args:        []string{"-node", testNode, "-confirm"},
+			expServices: []string{"other-1"},
+		},
+		"deregisters services with a custom source": {
+			args:        []string{"-node", testNode, "-source", "other-tool", "-confirm"},
+			expServices: []string{"api-1", "api-1-sidecar-proxy", "web-1", "web-1-sidecar-proxy"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, cfg := testutil.ConsulServer(t, nil)
+			consulClient, err := api.NewClient(cfg)
+			require.NoError(t, err)
+
+			for _, svc := range []struct{ id, source string }{
+				{"web-1", "consul-ecs"},
+				{"web-1-sidecar-proxy", "consul-ecs"},
+				{"api-1", "consul-ecs"},
+				{"api-1-sidecar-proxy", "consul-ecs"},
+				{"other-1", "other-tool"},
+			} {
+				_, err := consulClient.Catalog().Register(&api.CatalogRegistration{
+					Node:           testNode,
+					Address:        "127.0.0.1",
+					SkipNodeUpdate: true,
+					Service: &api.AgentService{
+						ID:      svc.id,
+						Service: svc.id,
+						Meta:    map[string]string{"source": svc.source},
+					},
+				}, nil)
+				require.NoError(t, err)
+			}
+
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			code := cmd.Run(c.args)
+			require.Equal(t, 0, code, ui.ErrorWriter.String())
+
+			nodeServices, _, err := consulClient.Catalog().NodeServiceList(testNode, nil)
+			require.NoError(t, err)
+			var actual []string
+			for _, svc := range nodeServices.Services {
+				actual = append(actual, svc.ID)
+			}
+			require.ElementsMatch(t, c.expServices, actual)
+		})
+	}
+}
@@ -4,8 +4,13 @@
 package meshinit
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/consul-ecs/awsutil"
 	"github.com/hashicorp/consul-ecs/config"
 	"github.com/hashicorp/consul/api"
 )
@@ -16,12 +21,18 @@ const (
 	consulHealthSyncCheckName = "Consul ECS health check synced"
 
 	consulDataplaneReadinessCheckName = "Consul dataplane readiness"
+
+	proxyHealthPollInterval = 1 * time.Second
 )
 
-func (c *Command) constructChecks(service *api.AgentService) api.HealthChecks {
+func (c *Command) constructChecks(service *api.AgentService, taskMeta awsutil.ECSTaskMeta) api.HealthChecks {
 	checks := make(api.HealthChecks, 0)
 	if service.Kind == api.ServiceKindTypical {
-		for _, containerName := range c.config.HealthSyncContainers {
+		for _, containerName := range c.config.GetHealthSyncContainers(taskMeta) {
+			notes := fmt.Sprintf("consul-ecs created and updates this check because the %s container has an ECS health check.", containerName)
+			if c.config.IsNonEssentialHealthSyncContainer(containerName) {
+				notes = fmt.Sprintf("consul-ecs created and updates this check because the non-essential %s container is listed in healthSyncNonEssentialContainers.", containerName)
+			}
 			checks = append(checks, &api.HealthCheck{
 				CheckID:   constructCheckID(service.ID, containerName),
 				Name:      consulHealthSyncCheckName,
@@ -30,11 +41,16 @@ func (c *Command) constructChecks(service *api.AgentService) api.HealthChecks {
 				Namespace: service.Namespace,
 				Status:    api.HealthCritical,
 				Output:    healthCheckOutputReason(api.HealthCritical, service.Service),
-				Notes:     fmt.Sprintf("consul-ecs created and updates this check because the %s container has an ECS health check.", containerName),
+				Notes:     notes,
 			})
 		}
 	}
 
+	// Without a sidecar, there is no dataplane to report readiness.
+	if service.Kind == api.ServiceKindTypical && !c.config.SidecarEnabled() {
+		return checks
+	}
+
 	// Add a custom check that indicates dataplane readiness
 	checks = append(checks, &api.HealthCheck{
 		CheckID:   constructCheckID(service.ID, config.ConsulDataplaneContainerName),
@@ -60,3 +76,34 @@ func healthCheckOutputReason(status, serviceName string) string {
 
 	return fmt.Sprintf("Service %s is not ready", serviceName)
 }
+
+// waitForProxyHealthy polls the health checks of the registered proxy until
+// they all pass. It returns an error if they do not pass within the timeout.
+func (c *Command) waitForProxyHealthy(ctx context.Context, consulClient *api.Client, registration *api.CatalogRegistration, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	service := registration.Service
+	opts := &api.QueryOptions{
+		Filter:    fmt.Sprintf("Node == %q and ServiceID == %q", registration.Node, service.ID),
+		Namespace: service.Namespace,
+		Partition: service.Partition,
+	}
+	err := backoff.RetryNotify(func() error {
+		checks, _, err := consulClient.Health().Checks(service.Service, opts.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		if len(checks) == 0 {
+			return fmt.Errorf("proxy %s has no health checks", service.ID)
+		}
+		if status := checks.AggregatedStatus(); status != api.HealthPassing {
+			return fmt.Errorf("proxy %s health is %s", service.ID, status)
+		}
+		return nil
+	}, backoff.WithContext(backoff.NewConstantBackOff(proxyHealthPollInterval), ctx), retryLogger(c.log))
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("proxy %s did not become healthy within %s", service.ID, timeout)
+	}
+	return err
+}
@@ -4,20 +4,31 @@
 package meshinit
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/consul-ecs/awsutil"
 	"github.com/hashicorp/consul-ecs/config"
 	"github.com/hashicorp/consul-ecs/testutil"
 	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
 	"github.com/stretchr/testify/require"
 )
 
 func TestConstructChecks(t *testing.T) {
 	cases := map[string]struct {
-		service              *api.AgentService
-		healthSyncContainers []string
-		expectedChecks       api.HealthChecks
+		service                *api.AgentService
+		healthSyncContainers   []string
+		nonEssentialContainers []string
+		taskContainers         []string
+		sidecarDisabled        bool
+		expectedChecks         api.HealthChecks
 	}{
 		"construct checks for the basic service": {
 			service: &api.AgentService{
@@ -56,6 +67,94 @@ func TestConstructChecks(t *testing.T) {
 				},
 			},
 		},
+		"construct checks for containers matching a wildcard": {
+			service: &api.AgentService{
+				ID:      "test-service-1234",
+				Service: "test-service",
+				Port:    8080,
+			},
+			healthSyncContainers: []string{"app-*"},
+			taskContainers:       []string{"app-web", "sidecar", "app-worker", config.ConsulDataplaneContainerName},
+			expectedChecks: api.HealthChecks{
+				&api.HealthCheck{
+					CheckID:   constructCheckID("test-service-1234", "app-web"),
+					Name:      consulHealthSyncCheckName,
+					Type:      consulECSCheckType,
+					ServiceID: "test-service-1234",
+					Status:    api.HealthCritical,
+					Output:    "Service test-service is not ready",
+					Notes:     "consul-ecs created and updates this check because the app-web container has an ECS health check.",
+				},
+				&api.HealthCheck{
+					CheckID:   constructCheckID("test-service-1234", "app-worker"),
+					Name:      consulHealthSyncCheckName,
+					Type:      consulECSCheckType,
+					ServiceID: "test-service-1234",
+					Status:    api.HealthCritical,
+					Output:    "Service test-service is not ready",
+					Notes:     "consul-ecs created and updates this check because the app-worker container has an ECS health check.",
+				},
+				&api.HealthCheck{
+					CheckID:   constructCheckID("test-service-1234", config.ConsulDataplaneContainerName),
+					Name:      consulDataplaneReadinessCheckName,
+					Type:      consulECSCheckType,
+					ServiceID: "test-service-1234",
+					Status:    api.HealthCritical,
+					Output:    "Service test-service is not ready",
+					Notes:     "consul-ecs created and updates this check to indicate consul-dataplane container's readiness",
+				},
+			},
+		},
+		"construct checks for non-essential containers": {
+			service: &api.AgentService{
+				ID:      "test-service-1234",
+				Service: "test-service",
+				Port:    8080,
+			},
+			healthSyncContainers:   []string{"container1"},
+			nonEssentialContainers: []string{"log-router"},
+			sidecarDisabled:        true,
+			expectedChecks: api.HealthChecks{
+				&api.HealthCheck{
+					CheckID:   constructCheckID("test-service-1234", "container1"),
+					Name:      consulHealthSyncCheckName,
+					Type:      consulECSCheckType,
+					ServiceID: "test-service-1234",
+					Status:    api.HealthCritical,
+					Output:    "Service test-service is not ready",
+					Notes:     "consul-ecs created and updates this check because the container1 container has an ECS health check.",
+				},
+				&api.HealthCheck{
+					CheckID:   constructCheckID("test-service-1234", "log-router"),
+					Name:      consulHealthSyncCheckName,
+					Type:      consulECSCheckType,
+					ServiceID: "test-service-1234",
+					Status:    api.HealthCritical,
+					Output:    "Service test-service is not ready",
+					Notes:     "consul-ecs created and updates this check because the non-essential log-router container is listed in healthSyncNonEssentialContainers.",
+				},
+			},
+		},
+		"construct no checks for a pattern matching no containers": {
+			service: &api.AgentService{
+				ID:      "test-service-1234",
+				Service: "test-service",
+				Port:    8080,
+			},
+			healthSyncContainers: []string{"worker-*"},
+			taskContainers:       []string{"app-web", config.ConsulDataplaneContainerName},
+			expectedChecks: api.HealthChecks{
+				&api.HealthCheck{
+					CheckID:   constructCheckID("test-service-1234", config.ConsulDataplaneContainerName),
+					Name:      consulDataplaneReadinessCheckName,
+					Type:      consulECSCheckType,
+					ServiceID: "test-service-1234",
+					Status:    api.HealthCritical,
+					Output:    "Service test-service is not ready",
+					Notes:     "consul-ecs created and updates this check to indicate consul-dataplane container's readiness",
+				},
+			},
+		},
 		"construct checks for the sidecar proxy service": {
 			service: &api.AgentService{
 				ID:      "test-service-sidecar-proxy-1234",
@@ -94,6 +193,26 @@ func TestConstructChecks(t *testing.T) {
 				},
 			},
 		},
+		"service without a sidecar has no dataplane readiness check": {
+			service: &api.AgentService{
+				ID:      "test-service-1234",
+				Service: "test-service",
+				Port:    8080,
+			},
+			healthSyncContainers: []string{"container1"},
+			sidecarDisabled:      true,
+			expectedChecks: api.HealthChecks{
+				&api.HealthCheck{
+					CheckID:   constructCheckID("test-service-1234", "container1"),
+					Name:      consulHealthSyncCheckName,
+					Type:      consulECSCheckType,
+					ServiceID: "test-service-1234",
+					Status:    api.HealthCritical,
+					Output:    "Service test-service is not ready",
+					Notes:     "consul-ecs created and updates this check because the container1 container has an ECS health check.",
+				},
+			},
+		},
 	}
 
 	for name, c := range cases {
@@ -115,10 +234,77 @@ func TestConstructChecks(t *testing.T) {
 			ui := cli.NewMockUi()
 			cmd := Command{UI: ui}
 			cmd.config = &config.Config{
-				HealthSyncContainers: c.healthSyncContainers,
+				HealthSyncContainers:             c.healthSyncContainers,
+				HealthSyncNonEssentialContainers: c.nonEssentialContainers,
+			}
+			if c.sidecarDisabled {
+				cmd.config.Mesh.Sidecar = &config.Sidecar{Enabled: testutil.BoolPtr(false)}
 			}
 
-			require.Equal(t, c.expectedChecks, cmd.constructChecks(c.service))
+			var taskMeta awsutil.ECSTaskMeta
+			for _, name := range c.taskContainers {
+				taskMeta.Containers = append(taskMeta.Containers, awsutil.ECSTaskMetaContainer{Name: name})
+			}
+
+			require.Equal(t, c.expectedChecks, cmd.constructChecks(c.service, taskMeta))
+		})
+	}
+}
+
+func TestWaitForProxyHealthy(t *testing.T) {
+	registration := &api.CatalogRegistration{
+		Node: "test-node",
+		Service: &api.AgentService{
+			ID:      "service-abcdef-sidecar-proxy",
+			Service: "service-sidecar-proxy",
+			Kind:    api.ServiceKindConnectProxy,
+		},
+	}
+
+	cases := map[string]struct {
+		// criticalPolls is the number of polls that return a critical check
+		// before the check passes. A negative value means it never passes.
+		criticalPolls int32
+		expError      string
+	}{
+		"healthy immediately": {},
+		"healthy after a delay": {
+			criticalPolls: 1,
+		},
+		"never healthy": {
+			criticalPolls: -1,
+			expError:      "proxy service-abcdef-sidecar-proxy did not become healthy within 1.5s",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var polls int32
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/v1/health/checks/service-sidecar-proxy", r.URL.Path)
+				require.Equal(t, `Node == "test-node" and ServiceID == "service-abcdef-sidecar-proxy"`, r.URL.Query().Get("filter"))
+
+				status := api.HealthPassing
+				if n := atomic.AddInt32(&polls, 1); c.criticalPolls < 0 || n <= c.criticalPolls {
+					status = api.HealthCritical
+				}
+				require.NoError(t, json.NewEncoder(w).Encode(api.HealthChecks{
+					{Node: "test-node", ServiceID: "service-abcdef-sidecar-proxy", Status: api.HealthPassing},
+					{Node: "test-node", ServiceID: "service-abcdef-sidecar-proxy", Status: status},
+				}))
+			}))
+			t.Cleanup(consulServer.Close)
+
+			consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			cmd := Command{log: hclog.NewNullLogger()}
+			err = cmd.waitForProxyHealthy(context.Background(), consulClient, registration, 1500*time.Millisecond)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.criticalPolls+1, atomic.LoadInt32(&polls))
 		})
 	}
 }
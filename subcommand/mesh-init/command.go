@@ -4,15 +4,30 @@
 package meshinit
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/cenkalti/backoff/v4"
 	"github.com/hashicorp/consul-ecs/awsutil"
 	"github.com/hashicorp/consul-ecs/config"
@@ -21,6 +36,7 @@ import (
 	"github.com/hashicorp/consul-server-connection-manager/discovery"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-multierror"
 	"github.com/mitchellh/cli"
 )
 
@@ -28,27 +44,147 @@ type Command struct {
 	UI     cli.Ui
 	config *config.Config
 	log    hclog.Logger
+
+	flagSet                     *flag.FlagSet
+	flagRegisterTimeout         time.Duration
+	flagPartition               string
+	flagNamespace               string
+	flagDryRun                  bool
+	flagLogJSON                 bool
+	flagLogLevel                string
+	flagConfigFile              string
+	flagFormat                  string
+	flagWaitProxyHealthy        bool
+	flagWaitProxyHealthyTimeout time.Duration
+	once                        sync.Once
+
+	sigs chan os.Signal
 }
 
 const (
 	dataplaneConfigFileName = "consul-dataplane.json"
 	caCertFileName          = "consul-grpc-ca-cert.pem"
+	checksumFileSuffix      = ".sha256"
+
+	flagRegisterTimeout         = "register-timeout"
+	flagPartition               = "partition"
+	flagNamespace               = "namespace"
+	flagDryRun                  = "dry-run"
+	flagLogJSON                 = "log-json"
+	flagLogLevel                = "log-level"
+	flagConfigFile              = "config-file"
+	flagFormat                  = "format"
+	flagWaitProxyHealthy        = "wait-proxy-healthy"
+	flagWaitProxyHealthyTimeout = "wait-proxy-healthy-timeout"
+
+	defaultWaitProxyHealthyTimeout = 2 * time.Minute
+
+	// defaultRegistrationRetryInterval is the delay between registration
+	// attempts, and the initial delay of a configured exponential backoff.
+	defaultRegistrationRetryInterval = 1 * time.Second
+
+	formatText = "text"
+	formatJSON = "json"
+
+	// aclNotFoundMsg is returned by Consul when a token does not exist on the server.
+	aclNotFoundMsg = "ACL not found"
+
+	// alreadyExistsMsg is returned by Consul when creating a partition or
+	// namespace that was created concurrently, such as by another task.
+	alreadyExistsMsg = "already exists"
+
+	// defaultTenancy is the name of the default partition and namespace,
+	// which always exist.
+	defaultTenancy = "default"
+
+	// maxDescribeTasks is the maximum number of tasks per ECS DescribeTasks call.
+	maxDescribeTasks = 100
 )
 
+func (c *Command) init() {
+	c.sigs = make(chan os.Signal, 1)
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.DurationVar(&c.flagRegisterTimeout, flagRegisterTimeout, 0,
+		"Maximum time to spend registering the service and proxy with Consul, including the time spent in each request. "+
+			"Zero means no timeout, in which case registration is retried until it succeeds. "+
+			"Overrides `mesh.bootstrapTimeout` in the config when passed.")
+	c.flagSet.StringVar(&c.flagPartition, flagPartition, "",
+		"Consul admin partition to register the service and proxy in. Overrides the partition in the config [Consul Enterprise].")
+	c.flagSet.StringVar(&c.flagNamespace, flagNamespace, "",
+		"Consul namespace to register the service and proxy in. Overrides the namespace in the config [Consul Enterprise].")
+	c.flagSet.BoolVar(&c.flagDryRun, flagDryRun, false,
+		"Print the registrations and dataplane config as JSON and exit without contacting Consul or writing to the bootstrap directory. "+
+			"The ECS task metadata endpoint must still be reachable.")
+	c.flagSet.BoolVar(&c.flagLogJSON, flagLogJSON, false,
+		"Output logs in JSON format. Overrides `logFormat` in the config.")
+	c.flagSet.StringVar(&c.flagLogLevel, flagLogLevel, "",
+		"Log level for this command and the dataplane, one of TRACE, DEBUG, INFO, WARN or ERROR. Overrides `logLevel` in the config.")
+	c.flagSet.StringVar(&c.flagConfigFile, flagConfigFile, "",
+		fmt.Sprintf("Path to a file containing the config. Takes precedence over the %s environment variable.", config.ConfigEnvironmentVariable))
+	c.flagSet.StringVar(&c.flagFormat, flagFormat, formatText,
+		"Output format, one of text or json. With json, the registered service ID, proxy ID and node are printed to stdout "+
+			"as a single JSON object on success. Logs are always written to stderr.")
+	c.flagSet.BoolVar(&c.flagWaitProxyHealthy, flagWaitProxyHealthy, false,
+		"Wait for the proxy's Consul health checks to pass before exiting. The dataplane container must not wait for "+
+			"mesh-init to exit, or the proxy never becomes healthy.")
+	c.flagSet.DurationVar(&c.flagWaitProxyHealthyTimeout, flagWaitProxyHealthyTimeout, defaultWaitProxyHealthyTimeout,
+		fmt.Sprintf("Maximum time to wait for the proxy to become healthy with -%s.", flagWaitProxyHealthy))
+}
+
 func (c *Command) Run(args []string) int {
-	if len(args) > 0 {
-		c.UI.Error(fmt.Sprintf("unexpected argument: %v", args[0]))
+	c.once.Do(c.init)
+
+	if err := c.flagSet.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("invalid flags: %s", err))
+		return 1
+	}
+
+	if len(c.flagSet.Args()) > 0 {
+		c.UI.Error(fmt.Sprintf("unexpected argument: %v", c.flagSet.Args()[0]))
 		return 1
 	}
 
-	config, err := config.FromEnv()
+	if c.flagLogLevel != "" {
+		if level := hclog.LevelFromString(c.flagLogLevel); level < hclog.Trace || level > hclog.Error {
+			c.UI.Error(fmt.Sprintf("invalid flags: -%s must be one of TRACE, DEBUG, INFO, WARN or ERROR", flagLogLevel))
+			return 1
+		}
+	}
+
+	if c.flagRegisterTimeout < 0 {
+		c.UI.Error(fmt.Sprintf("invalid flags: -%s must not be negative", flagRegisterTimeout))
+		return 1
+	}
+
+	if c.flagWaitProxyHealthy && c.flagWaitProxyHealthyTimeout <= 0 {
+		c.UI.Error(fmt.Sprintf("invalid flags: -%s must be positive", flagWaitProxyHealthyTimeout))
+		return 1
+	}
+
+	if c.flagFormat != formatText && c.flagFormat != formatJSON {
+		c.UI.Error(fmt.Sprintf("invalid flags: -%s must be one of %s or %s", flagFormat, formatText, formatJSON))
+		return 1
+	}
+
+	var (
+		cfg *config.Config
+		err error
+	)
+	if c.flagConfigFile != "" {
+		cfg, err = config.FromFile(c.flagConfigFile)
+	} else {
+		cfg, err = config.FromEnv()
+	}
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("invalid config: %s", err))
 		return 1
 	}
-	c.config = config
+	c.config = cfg
 
-	c.log = logging.FromConfig(c.config).Logger()
+	c.log = c.logOpts().Logger()
+	for _, w := range c.config.Warnings() {
+		c.log.Warn(w)
+	}
 
 	err = c.realRun()
 	if err != nil {
@@ -58,11 +194,26 @@ func (c *Command) Run(args []string) int {
 	return 0
 }
 
-func (c *Command) realRun() error {
+func (c *Command) realRun() (err error) {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
 
-	taskMeta, err := awsutil.ECSTaskMetadata()
+	// On SIGTERM or SIGINT, abort any in-progress registration and
+	// deregister whatever was already registered before exiting.
+	signal.Notify(c.sigs, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(c.sigs)
+	signaled := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-c.sigs:
+			c.log.Info("received signal, stopping mesh-init", "signal", sig.String())
+			close(signaled)
+			cancelFunc()
+		case <-ctx.Done():
+		}
+	}()
+
+	taskMeta, err := awsutil.CachedECSTaskMetadata()
 	if err != nil {
 		return err
 	}
@@ -71,6 +222,23 @@ func (c *Command) realRun() error {
 	if err != nil {
 		return err
 	}
+	nodeName := c.config.Mesh.GetNodeName(clusterARN)
+
+	if c.flagDryRun {
+		return c.dryRun(taskMeta, nodeName)
+	}
+
+	// Fail before registering, so that a bad bootstrap directory does not
+	// leave registrations in Consul for a task that cannot start.
+	err = checkBootstrapDir(c.config.BootstrapDir)
+	if err != nil {
+		return err
+	}
+
+	err = c.loadRPCCACertSecret(taskMeta)
+	if err != nil {
+		return err
+	}
 
 	serverConnMgrCfg, err := c.config.ConsulServerConnMgrConfig(taskMeta)
 	if err != nil {
@@ -95,39 +263,61 @@ func (c *Command) realRun() error {
 		return fmt.Errorf("constructing consul client from config: %s", err)
 	}
 
-	var serviceRegistration, proxyRegistration *api.CatalogRegistration
-	if c.config.Gateway != nil && c.config.Gateway.Kind != "" {
-		proxyRegistration = c.constructGatewayProxyRegistration(taskMeta, clusterARN)
-	} else {
-		serviceRegistration = c.constructServiceRegistration(taskMeta, clusterARN)
-		proxyRegistration = c.constructProxyRegistration(serviceRegistration, taskMeta, clusterARN)
+	var registered []*api.CatalogRegistration
+	defer func() {
+		select {
+		case <-signaled:
+			c.deregister(consulClient, registered)
+		default:
+			if err != nil {
+				c.rollback(consulClient, registered, err)
+			}
+		}
+	}()
+
+	c.applyTenancyOverrides(ctx, consulClient)
+
+	err = c.ensureTenancy(ctx, consulClient)
+	if err != nil {
+		return err
 	}
 
-	if serviceRegistration != nil {
-		// No need to register the service for gateways.
-		err = backoff.RetryNotify(func() error {
-			c.log.Info("registering service")
-			_, regErr := consulClient.Catalog().Register(serviceRegistration, nil)
-			return regErr
-		}, backoff.NewConstantBackOff(1*time.Second), retryLogger(c.log))
+	var serviceRegistration, proxyRegistration *api.CatalogRegistration
+	if c.config.Gateway != nil && c.config.Gateway.Kind != "" {
+		err = c.setGatewayPublicWanAddress(taskMeta, clusterARN)
 		if err != nil {
 			return err
 		}
-
-		c.log.Info("service registered successfully", "name", serviceRegistration.Service.Service, "id", serviceRegistration.Service.ID)
+		proxyRegistration = c.constructGatewayProxyRegistration(taskMeta, nodeName)
+		if c.config.Gateway.Kind == api.ServiceKindMeshGateway {
+			c.logMeshGatewayAddresses(proxyRegistration.Service)
+		}
+	} else {
+		if len(c.config.Proxy.Config) > 0 {
+			c.log.Warn("proxy.config is passed through to the proxy registration without validation")
+		}
+		serviceRegistration = c.constructServiceRegistration(taskMeta, nodeName)
+		if c.config.SidecarEnabled() {
+			proxyRegistration = c.constructProxyRegistration(serviceRegistration, taskMeta, nodeName)
+		} else if c.config.Service.ConnectNative {
+			c.log.Info("skipping proxy registration because service.connectNative is true")
+		} else {
+			c.log.Info("skipping proxy registration because mesh.sidecar.enabled is false")
+		}
 	}
 
-	// Register the proxy.
-	err = backoff.RetryNotify(func() error {
-		c.log.Info("registering proxy", "kind", proxyRegistration.Service.Kind)
-		_, regErr := consulClient.Catalog().Register(proxyRegistration, nil)
-		return regErr
-	}, backoff.NewConstantBackOff(1*time.Second), retryLogger(c.log))
+	registered, err = c.register(ctx, consulClient, serviceRegistration, proxyRegistration)
 	if err != nil {
 		return err
 	}
 
-	c.log.Info("proxy registered successfully", "name", proxyRegistration.Service.Service, "id", proxyRegistration.Service.ID)
+	if c.config.Mesh.DeregisterStaleInstances && serviceRegistration != nil {
+		// Cleanup is best effort and must not prevent the task from starting.
+		err = c.deregisterStaleInstancesOfTask(taskMeta, clusterARN, consulClient, serviceRegistration, proxyRegistration)
+		if err != nil {
+			c.log.Warn("failed to deregister stale instances", "error", err)
+		}
+	}
 
 	err = c.copyECSBinaryToSharedVolume()
 	if err != nil {
@@ -139,17 +329,37 @@ func (c *Command) realRun() error {
 		return err
 	}
 
-	var loginCreds *discovery.Credentials
-	if c.config.ConsulLogin.Enabled {
-		loginCreds = &serverConnMgrCfg.Credentials
+	if proxyRegistration != nil {
+		var loginCreds *discovery.Credentials
+		if c.config.ConsulLogin.Enabled {
+			loginCreds = &serverConnMgrCfg.Credentials
+		}
+
+		err = c.generateAndWriteDataplaneConfig(proxyRegistration, loginCreds, rpcCACertFile)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = c.generateAndWriteDataplaneConfig(proxyRegistration, loginCreds, rpcCACertFile)
+	err = c.writeSummary(serviceRegistration, proxyRegistration, rpcCACertFile)
 	if err != nil {
 		return err
 	}
 
+	if c.flagWaitProxyHealthy && proxyRegistration != nil {
+		c.log.Info("waiting for the proxy to become healthy", "timeout", c.flagWaitProxyHealthyTimeout.String())
+		err = c.waitForProxyHealthy(ctx, consulClient, proxyRegistration, c.flagWaitProxyHealthyTimeout)
+		if err != nil {
+			return err
+		}
+		c.log.Info("proxy is healthy", "id", proxyRegistration.Service.ID)
+	}
+
 	c.log.Info("successfully initialized the task to operate as part of the mesh")
+
+	if c.flagFormat == formatJSON {
+		return c.outputRegistration(serviceRegistration, proxyRegistration)
+	}
 	return nil
 }
 
@@ -158,7 +368,12 @@ func (c *Command) Synopsis() string {
 }
 
 func (c *Command) Help() string {
-	return ""
+	c.once.Do(c.init)
+
+	var buf strings.Builder
+	c.flagSet.SetOutput(&buf)
+	c.flagSet.PrintDefaults()
+	return "Usage: consul-ecs mesh-init [options]\n\n" + buf.String()
 }
 
 func retryLogger(log hclog.Logger) backoff.Notify {
@@ -167,6 +382,400 @@ func retryLogger(log hclog.Logger) backoff.Notify {
 	}
 }
 
+// logOpts returns the log settings from the config, with the
+// log flags taking precedence when they are passed.
+func (c *Command) logOpts() *logging.LogOpts {
+	opts := logging.FromConfig(c.config)
+	if c.flagLogLevel != "" {
+		opts.LogLevel = strings.ToUpper(c.flagLogLevel)
+	}
+	c.flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == flagLogJSON {
+			opts.LogJSON = c.flagLogJSON
+		}
+	})
+	return opts
+}
+
+// registerTimeout returns the maximum time to spend registering with Consul.
+// The -register-timeout flag takes precedence over `mesh.bootstrapTimeout`
+// when it is passed, including when it is zero. Zero means registration is
+// retried until it succeeds.
+func (c *Command) registerTimeout() time.Duration {
+	timeout := time.Duration(c.config.Mesh.BootstrapTimeout)
+	if c.flagSet == nil {
+		return timeout
+	}
+	c.flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == flagRegisterTimeout {
+			timeout = c.flagRegisterTimeout
+		}
+	})
+	return timeout
+}
+
+// applyTenancyOverrides applies the -partition and -namespace flags to the
+// service or gateway config before the registrations are constructed. The
+// flags are ignored with a warning if the Consul servers are not Consul Enterprise.
+func (c *Command) applyTenancyOverrides(ctx context.Context, consulClient *api.Client) {
+	if c.flagPartition == "" && c.flagNamespace == "" {
+		return
+	}
+
+	enterprise, err := isConsulEnterprise(ctx, consulClient)
+	if err != nil {
+		c.log.Warn("unable to determine the Consul edition, ignoring the partition and namespace flags", "error", err)
+		return
+	} else if !enterprise {
+		c.log.Warn("partitions and namespaces require Consul Enterprise, ignoring the partition and namespace flags")
+		return
+	}
+
+	c.setTenancyOverrides()
+}
+
+// setTenancyOverrides sets the partition and namespace in the service or
+// gateway config from the -partition and -namespace flags.
+func (c *Command) setTenancyOverrides() {
+	partition, namespace := &c.config.Service.Partition, &c.config.Service.Namespace
+	if c.config.IsGateway() {
+		partition, namespace = &c.config.Gateway.Partition, &c.config.Gateway.Namespace
+	}
+	if c.flagPartition != "" {
+		*partition = c.flagPartition
+	}
+	if c.flagNamespace != "" {
+		*namespace = c.flagNamespace
+	}
+}
+
+// ensureTenancy creates the partition and namespace of the service or gateway
+// if they do not exist, when mesh.createPartition or mesh.createNamespace are set.
+// It is safe to call repeatedly and from concurrent tasks.
+func (c *Command) ensureTenancy(ctx context.Context, consulClient *api.Client) error {
+	partition, namespace := c.config.Service.Partition, c.config.Service.Namespace
+	if c.config.IsGateway() {
+		partition, namespace = c.config.Gateway.Partition, c.config.Gateway.Namespace
+	}
+
+	if c.config.Mesh.CreatePartition && partition != "" && partition != defaultTenancy {
+		existing, _, err := consulClient.Partitions().Read(ctx, partition, nil)
+		if err != nil {
+			return fmt.Errorf("reading partition %s: %w", partition, err)
+		}
+		if existing == nil {
+			_, _, err = consulClient.Partitions().Create(ctx, &api.Partition{Name: partition}, nil)
+			if err == nil {
+				c.log.Info("created partition", "partition", partition)
+			} else if !strings.Contains(err.Error(), alreadyExistsMsg) {
+				return fmt.Errorf("creating partition %s: %w", partition, err)
+			}
+		}
+	}
+
+	if c.config.Mesh.CreateNamespace && namespace != "" && namespace != defaultTenancy {
+		existing, _, err := consulClient.Namespaces().Read(namespace, (&api.QueryOptions{Partition: partition}).WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("reading namespace %s: %w", namespace, err)
+		}
+		if existing == nil {
+			_, _, err = consulClient.Namespaces().Create(&api.Namespace{Name: namespace, Partition: partition},
+				(&api.WriteOptions{Partition: partition}).WithContext(ctx))
+			if err == nil {
+				c.log.Info("created namespace", "namespace", namespace, "partition", partition)
+			} else if !strings.Contains(err.Error(), alreadyExistsMsg) {
+				return fmt.Errorf("creating namespace %s: %w", namespace, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isConsulEnterprise returns true if the Consul servers support admin partitions.
+// It reads the default partition, which always exists in Consul Enterprise, rather
+// than an agent endpoint: the service token from the login has no agent:read.
+// Consul CE does not serve the partition endpoints and responds with a 404.
+// A permission denied response still means the endpoint exists.
+func isConsulEnterprise(ctx context.Context, consulClient *api.Client) (bool, error) {
+	partition, _, err := consulClient.Partitions().Read(ctx, api.PartitionDefaultName, nil)
+	if err != nil {
+		var statusErr api.StatusError
+		if errors.As(err, &statusErr) && statusErr.Code == http.StatusForbidden {
+			return true, nil
+		}
+		return false, err
+	}
+	return partition != nil, nil
+}
+
+// registerWithRetry registers the given entity in the Consul catalog. Failed
+// requests are retried with registrationBackOff() until the registration
+// succeeds or ctx is done.
+// A request in flight when ctx is done is cancelled.
+// Errors that cannot succeed on retry abort the registration immediately.
+func (c *Command) registerWithRetry(ctx context.Context, consulClient *api.Client, registration *api.CatalogRegistration) error {
+	opts := (&api.WriteOptions{}).WithContext(ctx)
+	return backoff.RetryNotify(func() error {
+		_, err := consulClient.Catalog().Register(registration, opts)
+		if err != nil && !isRetryableRegistrationError(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(c.registrationBackOff(), ctx), retryLogger(c.log))
+}
+
+// registrationBackOff returns the backoff between registration attempts. It is
+// a constant backoff unless mesh.retry configures an exponential backoff.
+func (c *Command) registrationBackOff() backoff.BackOff {
+	retry := c.config.Mesh.Retry
+	if !retry.IsSet() {
+		return backoff.NewConstantBackOff(defaultRegistrationRetryInterval)
+	}
+
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = defaultRegistrationRetryInterval
+	if retry.InitialInterval > 0 {
+		b.InitialInterval = time.Duration(retry.InitialInterval)
+	}
+	if retry.MaxInterval > 0 {
+		b.MaxInterval = time.Duration(retry.MaxInterval)
+	}
+	if b.InitialInterval > b.MaxInterval {
+		b.InitialInterval = b.MaxInterval
+	}
+	if retry.Multiplier > 0 {
+		b.Multiplier = retry.Multiplier
+	}
+	// The register timeout bounds the retries instead.
+	b.MaxElapsedTime = 0
+	b.Reset()
+	return b
+}
+
+// isRetryableRegistrationError returns false for 4xx responses from Consul,
+// such as a malformed registration, since they will fail on every attempt.
+// Rate limiting (429) and ACL not found (403) errors are retryable. The latter
+// happens when the token has not yet replicated to the server being queried.
+func isRetryableRegistrationError(err error) bool {
+	var statusErr api.StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	switch {
+	case statusErr.Code == http.StatusTooManyRequests:
+		return true
+	case statusErr.Code == http.StatusForbidden && strings.Contains(statusErr.Body, aclNotFoundMsg):
+		return true
+	case statusErr.Code >= 400 && statusErr.Code < 500:
+		return false
+	}
+	return true
+}
+
+// register registers the service, unless the task is a gateway, and the proxy
+// with Consul. It returns the registrations that succeeded, including when a
+// later registration fails, so that the caller can roll them back.
+func (c *Command) register(ctx context.Context, consulClient *api.Client, serviceRegistration, proxyRegistration *api.CatalogRegistration) ([]*api.CatalogRegistration, error) {
+	var registered []*api.CatalogRegistration
+
+	// The register timeout is shared by the service and proxy registrations and
+	// the config entry writes.
+	registerCtx := ctx
+	registerTimeout := c.registerTimeout()
+	if registerTimeout > 0 {
+		var registerCancel context.CancelFunc
+		registerCtx, registerCancel = context.WithTimeout(ctx, registerTimeout)
+		defer registerCancel()
+	}
+
+	if serviceRegistration != nil {
+		// No need to register the service for gateways.
+		c.log.Info("registering service")
+		err := c.registerWithRetry(registerCtx, consulClient, serviceRegistration)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return registered, fmt.Errorf("service registration did not succeed within %s", registerTimeout)
+		} else if err != nil {
+			return registered, err
+		}
+
+		registered = append(registered, serviceRegistration)
+		c.log.Info("service registered successfully", "name", serviceRegistration.Service.Service, "id", serviceRegistration.Service.ID)
+	}
+
+	if c.config.IsGateway() {
+		err := c.writeGatewayConfigEntry(registerCtx, consulClient, proxyRegistration)
+		if err != nil {
+			return registered, err
+		}
+	}
+
+	if proxyRegistration != nil {
+		err := c.writeProxyDefaults(registerCtx, consulClient, proxyRegistration.Service.Partition)
+		if err != nil {
+			return registered, err
+		}
+
+		// Register the proxy.
+		c.log.Info("registering proxy", "kind", proxyRegistration.Service.Kind)
+		err = c.registerWithRetry(registerCtx, consulClient, proxyRegistration)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return registered, fmt.Errorf("proxy registration did not succeed within %s", registerTimeout)
+		} else if err != nil {
+			return registered, err
+		}
+
+		registered = append(registered, proxyRegistration)
+		c.log.Info("proxy registered successfully", "name", proxyRegistration.Service.Service, "id", proxyRegistration.Service.ID)
+	}
+	return registered, nil
+}
+
+// rollback deregisters the services that mesh-init registered before it
+// failed with err, unless mesh.rollbackOnFailure is false. This keeps a
+// partial registration, such as a service whose proxy failed to register,
+// out of the catalog.
+func (c *Command) rollback(consulClient *api.Client, registered []*api.CatalogRegistration, err error) {
+	if len(registered) == 0 || !c.config.Mesh.GetRollbackOnFailure() {
+		return
+	}
+	c.log.Info("rolling back registrations after failure", "error", err)
+	c.deregister(consulClient, registered)
+}
+
+// deregister deregisters the services that mesh-init registered, either
+// after it was signaled to stop or when it failed before completing. It is a
+// no-op if nothing was registered.
+func (c *Command) deregister(consulClient *api.Client, registered []*api.CatalogRegistration) {
+	for _, reg := range registered {
+		_, err := consulClient.Catalog().Deregister(&api.CatalogDeregistration{
+			Node:      reg.Node,
+			ServiceID: reg.Service.ID,
+			Namespace: reg.Service.Namespace,
+			Partition: reg.Service.Partition,
+		}, nil)
+		if err != nil {
+			c.log.Error("failed to deregister service", "id", reg.Service.ID, "error", err)
+			continue
+		}
+		c.log.Info("deregistered service", "id", reg.Service.ID)
+	}
+}
+
+// deregisterStaleInstancesOfTask deregisters the stale instances of the
+// service, using the task's credentials to describe the other tasks in ECS.
+func (c *Command) deregisterStaleInstancesOfTask(taskMeta awsutil.ECSTaskMeta, clusterARN string, consulClient *api.Client, serviceRegistration, proxyRegistration *api.CatalogRegistration) error {
+	clientSession, err := awsutil.NewSession(taskMeta, "mesh-init")
+	if err != nil {
+		return err
+	}
+	return c.deregisterStaleInstances(consulClient, ecs.New(clientSession), clusterARN, serviceRegistration, proxyRegistration)
+}
+
+// deregisterStaleInstances deregisters instances of the service and its sidecar
+// proxy that were registered on the cluster node by tasks that have stopped.
+// Every task of the cluster registers on the same node, so an instance is only
+// stale once ECS reports its task as STOPPED, or no longer finds it, the same
+// way the controller decides. Instances without a `task-arn` meta and gateways
+// are never deregistered.
+func (c *Command) deregisterStaleInstances(consulClient *api.Client, ecsClient ecsiface.ECSAPI, clusterARN string, serviceRegistration, proxyRegistration *api.CatalogRegistration) error {
+	service := serviceRegistration.Service
+	// Without a sidecar, only stale instances of the service are deregistered.
+	proxy := &api.AgentService{}
+	if proxyRegistration != nil {
+		proxy = proxyRegistration.Service
+	}
+	taskARN := service.Meta["task-arn"]
+
+	nodeServices, _, err := consulClient.Catalog().NodeServiceList(serviceRegistration.Node, &api.QueryOptions{
+		Namespace: service.Namespace,
+		Partition: service.Partition,
+	})
+	if err != nil {
+		return fmt.Errorf("listing services for node %s: %w", serviceRegistration.Node, err)
+	}
+	if nodeServices == nil {
+		return nil
+	}
+
+	// Group the instances of other tasks by the task that registered them.
+	candidates := make(map[string][]*api.AgentService)
+	for _, svc := range nodeServices.Services {
+		if svc.Service != service.Service && svc.Service != proxy.Service {
+			continue
+		}
+		if svc.ID == service.ID || svc.ID == proxy.ID {
+			continue
+		}
+		if svc.Kind != api.ServiceKindTypical && svc.Kind != api.ServiceKindConnectProxy {
+			continue
+		}
+		otherTaskARN, ok := svc.Meta["task-arn"]
+		if !ok || otherTaskARN == taskARN {
+			continue
+		}
+		candidates[otherTaskARN] = append(candidates[otherTaskARN], svc)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	taskARNs := make([]string, 0, len(candidates))
+	for arn := range candidates {
+		taskARNs = append(taskARNs, arn)
+	}
+	sort.Strings(taskARNs)
+	running, err := runningTasks(ecsClient, clusterARN, taskARNs)
+	if err != nil {
+		return err
+	}
+
+	var result error
+	for _, arn := range taskARNs {
+		if running[arn] {
+			continue
+		}
+		for _, svc := range candidates[arn] {
+			_, err = consulClient.Catalog().Deregister(&api.CatalogDeregistration{
+				Node:      serviceRegistration.Node,
+				ServiceID: svc.ID,
+				Namespace: svc.Namespace,
+				Partition: svc.Partition,
+			}, nil)
+			if err != nil {
+				result = multierror.Append(result, fmt.Errorf("deregistering %s: %w", svc.ID, err))
+				continue
+			}
+			c.log.Info("deregistered stale instance", "id", svc.ID, "task-arn", arn)
+		}
+	}
+	return result
+}
+
+// runningTasks returns the ARNs of the given tasks that ECS reports as not
+// STOPPED. Tasks that ECS does not find are left out.
+func runningTasks(ecsClient ecsiface.ECSAPI, clusterARN string, taskARNs []string) (map[string]bool, error) {
+	running := make(map[string]bool)
+	for start := 0; start < len(taskARNs); start += maxDescribeTasks {
+		end := start + maxDescribeTasks
+		if end > len(taskARNs) {
+			end = len(taskARNs)
+		}
+		output, err := ecsClient.DescribeTasks(&ecs.DescribeTasksInput{
+			Cluster: aws.String(clusterARN),
+			Tasks:   aws.StringSlice(taskARNs[start:end]),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("describing tasks: %w", err)
+		}
+		for _, task := range output.Tasks {
+			if aws.StringValue(task.LastStatus) != ecs.DesiredStatusStopped {
+				running[aws.StringValue(task.TaskArn)] = true
+			}
+		}
+	}
+	return running, nil
+}
+
 func (c *Command) setupConsulAPIClient(state discovery.State) (*api.Client, error) {
 	// Client config for the client that talks directly to the server agent
 	cfg := c.config.ClientConfig()
@@ -177,9 +786,13 @@ func (c *Command) setupConsulAPIClient(state discovery.State) (*api.Client, erro
 		// already implements a sleep that should mitigate this. If not, we should reintroduce the
 		// `waitForReplication` method removed in https://github.com/hashicorp/consul-ecs/pull/143
 		cfg.Token = state.Token
+	} else {
+		// Without a token from login, fall back to CONSUL_HTTP_TOKEN, which may be
+		// injected from a Secrets Manager secret in the task definition.
+		cfg.Token = config.GetConsulToken()
 	}
 
-	return api.NewClient(cfg)
+	return c.config.NewConsulAPIClient(cfg)
 }
 
 // constructServiceName returns the service name for registration with Consul.
@@ -205,30 +818,44 @@ func (c *Command) constructServiceName(family string) string {
 
 // constructServiceRegistration returns the service registration request body.
 // May return an error due to invalid inputs from the config file.
-func (c *Command) constructServiceRegistration(taskMeta awsutil.ECSTaskMeta, clusterARN string) *api.CatalogRegistration {
+func (c *Command) constructServiceRegistration(taskMeta awsutil.ECSTaskMeta, nodeName string) *api.CatalogRegistration {
 	serviceName := c.constructServiceName(taskMeta.Family)
 	taskID := taskMeta.TaskID()
-	serviceID := makeServiceID(serviceName, taskID)
+	serviceID := makeServiceID(serviceName, c.config.Mesh.GetServiceIDSuffix(taskMeta))
 
-	fullMeta := mergeMeta(map[string]string{
-		"task-id":  taskID,
-		"task-arn": taskMeta.TaskARN,
-		"source":   "consul-ecs",
-	}, c.config.Service.Meta)
+	baseMeta := map[string]string{
+		"task-id":     taskID,
+		"task-arn":    taskMeta.TaskARN,
+		"task-family": taskMeta.Family,
+		"source":      c.config.Service.GetSourceTag(),
+	}
+	if cpu := taskMeta.Limits.CPU; cpu != nil {
+		baseMeta["task-cpu"] = strconv.FormatFloat(*cpu, 'f', -1, 64)
+	}
+	if memory := taskMeta.Limits.Memory; memory != nil {
+		baseMeta["task-memory"] = strconv.FormatFloat(*memory, 'f', -1, 64)
+	}
+	fullMeta := mergeMeta(baseMeta, c.config.Service.Meta)
 
 	service := c.config.Service.ToConsulType()
 	service.ID = serviceID
 	service.Service = serviceName
 	service.Meta = fullMeta
 	service.Address = taskMeta.NodeIP()
+	// Additional ports are only advertised. The proxy routes inbound traffic to service.Port.
+	service.TaggedAddresses = c.config.Service.AdditionalPortAddresses(service.Address)
+	service.TaggedAddresses = addLANIPTaggedAddresses(service.TaggedAddresses, taskMeta, service.Port)
 
 	service.Locality = getLocalityParams(taskMeta)
+	if c.config.Service.AddLocalityTags {
+		service.Tags = appendLocalityTags(service.Tags, service.Locality)
+	}
 
-	return c.constructCatalogRegistrationPayload(service, taskMeta, clusterARN)
+	return c.constructCatalogRegistrationPayload(service, taskMeta, nodeName)
 }
 
 // constructProxyRegistration returns the proxy registration request body.
-func (c *Command) constructProxyRegistration(serviceRegistration *api.CatalogRegistration, taskMeta awsutil.ECSTaskMeta, clusterARN string) *api.CatalogRegistration {
+func (c *Command) constructProxyRegistration(serviceRegistration *api.CatalogRegistration, taskMeta awsutil.ECSTaskMeta, nodeName string) *api.CatalogRegistration {
 	proxySvcID, proxySvcName := makeProxySvcIDAndName(serviceRegistration.Service.ID, serviceRegistration.Service.Service)
 	proxyService := &api.AgentService{
 		ID:                proxySvcID,
@@ -245,28 +872,34 @@ func (c *Command) constructProxyRegistration(serviceRegistration *api.CatalogReg
 		EnableTagOverride: serviceRegistration.Service.EnableTagOverride,
 		Locality:          serviceRegistration.Service.Locality,
 	}
+	proxyService.TaggedAddresses = addLANIPTaggedAddresses(nil, taskMeta, proxyService.Port)
 
 	proxyService.Proxy.DestinationServiceID = serviceRegistration.Service.ID
 	proxyService.Proxy.DestinationServiceName = serviceRegistration.Service.Service
-	proxyService.Proxy.LocalServicePort = serviceRegistration.Service.Port
+	if serviceRegistration.Service.SocketPath != "" {
+		proxyService.Proxy.LocalServiceSocketPath = serviceRegistration.Service.SocketPath
+	} else {
+		proxyService.Proxy.LocalServicePort = serviceRegistration.Service.Port
+	}
 
-	return c.constructCatalogRegistrationPayload(proxyService, taskMeta, clusterARN)
+	return c.constructCatalogRegistrationPayload(proxyService, taskMeta, nodeName)
 }
 
-func (c *Command) constructGatewayProxyRegistration(taskMeta awsutil.ECSTaskMeta, clusterARN string) *api.CatalogRegistration {
+func (c *Command) constructGatewayProxyRegistration(taskMeta awsutil.ECSTaskMeta, nodeName string) *api.CatalogRegistration {
 	serviceName := c.constructServiceName(taskMeta.Family)
 
 	taskID := taskMeta.TaskID()
-	serviceID := makeServiceID(serviceName, taskID)
+	serviceID := makeServiceID(serviceName, c.config.Mesh.GetServiceIDSuffix(taskMeta))
 
 	gatewaySvc := c.config.Gateway.ToConsulType()
 	gatewaySvc.ID = serviceID
 	gatewaySvc.Service = serviceName
 	gatewaySvc.Address = taskMeta.NodeIP()
 	gatewaySvc.Meta = mergeMeta(map[string]string{
-		"task-id":  taskID,
-		"task-arn": taskMeta.TaskARN,
-		"source":   "consul-ecs",
+		"task-id":     taskID,
+		"task-arn":    taskMeta.TaskARN,
+		"task-family": taskMeta.Family,
+		"source":      c.config.Gateway.GetSourceTag(),
 	}, c.config.Gateway.Meta)
 
 	switch c.config.Gateway.Kind {
@@ -288,8 +921,7 @@ func (c *Command) constructGatewayProxyRegistration(taskMeta awsutil.ECSTaskMeta
 			}
 		}
 
-		// TODO if assign_public_ip is set and the WAN address is not provided then
-		// we need to find the Public IP of the task (or LB) and use that for the WAN address.
+		// If `gateway.assignPublicIP` is set, the WAN address defaults to the public IP of the task.
 		if c.config.Gateway.WanAddress != nil {
 			wanAddr := c.config.Gateway.WanAddress.ToConsulType()
 			if wanAddr.Address != "" {
@@ -304,74 +936,199 @@ func (c *Command) constructGatewayProxyRegistration(taskMeta awsutil.ECSTaskMeta
 		}
 	}
 
-	return c.constructCatalogRegistrationPayload(gatewaySvc, taskMeta, clusterARN)
+	return c.constructCatalogRegistrationPayload(gatewaySvc, taskMeta, nodeName)
+}
+
+// logMeshGatewayAddresses logs the resolved addresses of the mesh gateway,
+// which are needed to configure WAN federation with other datacenters.
+func (c *Command) logMeshGatewayAddresses(gatewaySvc *api.AgentService) {
+	lan, wan := meshGatewayAddresses(gatewaySvc)
+	args := []interface{}{"lan", net.JoinHostPort(lan.Address, strconv.Itoa(lan.Port))}
+	if wan != nil {
+		args = append(args, "wan", net.JoinHostPort(wan.Address, strconv.Itoa(wan.Port)))
+	}
+	c.log.Info("resolved mesh gateway addresses", args...)
 }
 
-func (c *Command) constructCatalogRegistrationPayload(service *api.AgentService, taskMeta awsutil.ECSTaskMeta, clusterARN string) *api.CatalogRegistration {
+func (c *Command) constructCatalogRegistrationPayload(service *api.AgentService, taskMeta awsutil.ECSTaskMeta, nodeName string) *api.CatalogRegistration {
 	return &api.CatalogRegistration{
-		Node:           clusterARN,
-		NodeMeta:       getNodeMeta(),
+		Node:           nodeName,
+		NodeMeta:       mergeMeta(c.config.Mesh.NodeMeta, getNodeMeta()),
 		Address:        taskMeta.NodeIP(),
+		Datacenter:     c.config.ConsulServers.Datacenter,
 		Service:        service,
-		Checks:         c.constructChecks(service),
+		Checks:         c.constructChecks(service, taskMeta),
 		Partition:      service.Partition,
 		SkipNodeUpdate: true,
 	}
 }
 
+// checkBootstrapDir returns an error if the bootstrap directory does not
+// exist, is not a directory or is not writable.
+func checkBootstrapDir(dir string) error {
+	info, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("bootstrap directory %s does not exist", dir)
+	} else if err != nil {
+		return fmt.Errorf("checking bootstrap directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("bootstrap directory %s is not a directory", dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("bootstrap directory %s is not writable: %w", dir, err)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
 // copyECSBinaryToSharedVolume copies the consul-ecs binary to a volume.
 // This can be later used to perform health checks against envoy's public
 // listener port with the `netdial` command. The `app-entrypoint` and
 // `envoy-entrypoint` commands are also intended to be used with other
 // containers. This is one other reason to copy the binary to a shared volume.
 func (c *Command) copyECSBinaryToSharedVolume() error {
+	if !c.config.Mesh.GetCopyBinary() {
+		c.log.Info("skipping binary copy because mesh.copyBinary is disabled")
+		return nil
+	}
+
 	ex, err := os.Executable()
 	if err != nil {
 		return err
 	}
-	data, err := os.ReadFile(ex)
+
+	copyConsulECSBinary := c.config.Mesh.GetBinaryDestination(c.config.BootstrapDir)
+	if err := os.MkdirAll(path.Dir(copyConsulECSBinary), 0755); err != nil {
+		return fmt.Errorf("creating directory for binary destination %s: %w", copyConsulECSBinary, err)
+	}
+	sum, err := copyFile(ex, copyConsulECSBinary, 0755)
 	if err != nil {
 		return err
 	}
 
-	copyConsulECSBinary := path.Join(c.config.BootstrapDir, "consul-ecs")
-	err = os.WriteFile(copyConsulECSBinary, data, 0755)
+	// Verify the copy to catch truncated writes, such as on a full volume.
+	if err := verifyChecksum(copyConsulECSBinary, sum); err != nil {
+		_ = os.Remove(copyConsulECSBinary)
+		return err
+	}
+
+	// Write the checksum in the `sha256sum` format so that other containers
+	// can verify the binary with `sha256sum -c`.
+	checksumFile := copyConsulECSBinary + checksumFileSuffix
+	checksum := fmt.Sprintf("%x  %s\n", sum, path.Base(copyConsulECSBinary))
+	if err := os.WriteFile(checksumFile, []byte(checksum), 0644); err != nil {
+		return err
+	}
+	c.log.Info("copied binary", "file", copyConsulECSBinary, "sha256", fmt.Sprintf("%x", sum))
+	return nil
+}
+
+// copyFile streams the contents of src to dst, which is created with the given
+// mode, and returns the SHA-256 of the contents. The binary is tens of MB, so
+// it is not read into memory as a whole. If the copy fails, the incomplete dst
+// file is removed.
+func copyFile(src, dst string, mode os.FileMode) (sum []byte, err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(dst)
+		}
+	}()
+
+	h := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(out, h), in); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// verifyChecksum returns an error if the SHA-256 of the file does not match the expected sum.
+func verifyChecksum(file string, expected []byte) error {
+	f, err := os.Open(file)
 	if err != nil {
 		return err
 	}
-	c.log.Info("copied binary", "file", copyConsulECSBinary)
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected sha256 %x, got %x", file, expected, actual)
+	}
 	return nil
 }
 
-// generateAndWriteDataplaneConfig generates the configuration json
-// needed for dataplane to configure itself and writes it to a shared
-// volume.
-func (c *Command) generateAndWriteDataplaneConfig(proxyRegistration *api.CatalogRegistration, consulLoginCreds *discovery.Credentials, caCertFilePath string) error {
+// generateDataplaneConfig generates the configuration json
+// needed for dataplane to configure itself.
+func (c *Command) generateDataplaneConfig(proxyRegistration *api.CatalogRegistration, consulLoginCreds *discovery.Credentials, caCertFilePath string) ([]byte, error) {
+	return c.dataplaneConfigInput(proxyRegistration, consulLoginCreds, caCertFilePath).GetDataplaneConfigJSON()
+}
+
+// dataplaneConfigInput returns the inputs used to generate the dataplane configuration json.
+func (c *Command) dataplaneConfigInput(proxyRegistration *api.CatalogRegistration, consulLoginCreds *discovery.Credentials, caCertFilePath string) *dataplane.GetDataplaneConfigJSONInput {
 	input := &dataplane.GetDataplaneConfigJSONInput{
 		ProxyRegistration:      proxyRegistration,
 		ConsulServerConfig:     c.config.ConsulServers,
 		ConsulLoginCredentials: consulLoginCreds,
 		CACertFile:             caCertFilePath,
-		LogLevel:               logging.FromConfig(c.config).LogLevel,
+		LogLevel:               c.logOpts().LogLevel,
+		ProxyHealthCheckPort:   c.healthCheckPort(),
+		// The dataplane container mounts the bootstrap dir that the binary is copied to.
+		ConsulECSBinaryPath: c.config.Mesh.GetBinaryDestination(c.config.BootstrapDir),
 	}
+	return input
+}
 
+// healthCheckPort returns the port that the dataplane indicates Envoy's readiness on.
+func (c *Command) healthCheckPort() int {
 	if c.config.IsGateway() {
-		input.ProxyHealthCheckPort = config.GetHealthCheckPort(c.config.Gateway.HealthCheckPort)
-	} else {
-		input.ProxyHealthCheckPort = config.GetHealthCheckPort(c.config.Proxy.HealthCheckPort)
+		return config.GetHealthCheckPort(c.config.Gateway.HealthCheckPort)
 	}
+	return config.GetHealthCheckPort(c.config.Proxy.HealthCheckPort)
+}
 
-	dataplaneConfigPath := path.Join(c.config.BootstrapDir, dataplaneConfigFileName)
+// generateAndWriteDataplaneConfig generates the configuration json
+// needed for dataplane to configure itself and writes it to a shared
+// volume. At debug level, the config is also logged with the
+// credentials redacted.
+func (c *Command) generateAndWriteDataplaneConfig(proxyRegistration *api.CatalogRegistration, consulLoginCreds *discovery.Credentials, caCertFilePath string) error {
+	input := c.dataplaneConfigInput(proxyRegistration, consulLoginCreds, caCertFilePath)
 	configJSON, err := input.GetDataplaneConfigJSON()
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(dataplaneConfigPath, configJSON, 0444)
+	dataplaneConfigPath := path.Join(c.config.BootstrapDir, dataplaneConfigFileName)
+	err = os.WriteFile(dataplaneConfigPath, configJSON, c.config.Mesh.GetBootstrapFileMode())
 	if err != nil {
 		return err
 	}
 	c.log.Info("wrote dataplane config to ", dataplaneConfigPath)
+
+	if c.log.IsDebug() {
+		redactedJSON, err := input.Redacted().GetDataplaneConfigJSON()
+		if err != nil {
+			return err
+		}
+		c.log.Debug("generated dataplane config", "config", string(redactedJSON))
+	}
 	return nil
 }
 
@@ -381,26 +1138,59 @@ func (c *Command) generateAndWriteDataplaneConfig(proxyRegistration *api.Catalog
 //  2. CONSUL_GRPC_CACERT_PEM should contain the PEM. If not,
 //     we rely on `consulServers.defaults.caCertFile` or `consulServers.grpc.caCertFile`
 //
+// CONSUL_GRPC_CACERT_PEM may contain several certificates, which are written
+// as a single bundle. See config.GetGRPCCACertBundle.
+//
 // This is done because dataplane always expects a CA cert file path to be passed for
 // configuring it's own TLS settings.
 func (c *Command) writeRPCCACertToSharedVolume() (string, error) {
+	caCertPath, pemData := c.rpcCACert()
+	if pemData == "" {
+		return caCertPath, nil
+	}
+
+	bundle, err := config.GetGRPCCACertBundle(c.log)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.WriteFile(caCertPath, bundle, c.config.Mesh.GetBootstrapFileMode())
+	if err != nil {
+		return "", err
+	}
+
+	return caCertPath, nil
+}
+
+// rpcCACert returns the CA cert file path passed to dataplane and, if the
+// cert is provided through CONSUL_GRPC_CACERT_PEM, the PEM to write to that path.
+func (c *Command) rpcCACert() (string, string) {
 	tlsSettings := c.config.ConsulServers.GetGRPCTLSSettings()
 	if !tlsSettings.Enabled {
-		return "", nil
+		return "", ""
 	}
 
 	pem := os.Getenv(config.ConsulGRPCCACertPemEnvVar)
 	if pem == "" {
-		return tlsSettings.CaCertFile, nil
+		return tlsSettings.CaCertFile, ""
 	}
+	return path.Join(c.config.BootstrapDir, caCertFileName), pem
+}
 
-	caCertPath := path.Join(c.config.BootstrapDir, caCertFileName)
-	err := os.WriteFile(caCertPath, []byte(pem), 0444)
-	if err != nil {
-		return "", err
+// loadRPCCACertSecret fetches the gRPC CA cert from Secrets Manager when
+// consulServers.grpc.caCertSecretArn is set. The cert is then used for the
+// connection to the Consul servers and written to the shared volume for
+// consul-dataplane, like a cert passed in CONSUL_GRPC_CACERT_PEM.
+func (c *Command) loadRPCCACertSecret(taskMeta awsutil.ECSTaskMeta) error {
+	if c.config.ConsulServers.GRPC.CaCertSecretARN == "" {
+		return nil
 	}
 
-	return caCertPath, nil
+	clientSession, err := awsutil.NewSession(taskMeta, "mesh-init")
+	if err != nil {
+		return err
+	}
+	return c.config.LoadGRPCCACertSecret(secretsmanager.New(clientSession))
 }
 
 func getNodeMeta() map[string]string {
@@ -409,8 +1199,8 @@ func getNodeMeta() map[string]string {
 	}
 }
 
-func makeServiceID(serviceName, taskID string) string {
-	return fmt.Sprintf("%s-%s", serviceName, taskID)
+func makeServiceID(serviceName, idSuffix string) string {
+	return fmt.Sprintf("%s-%s", serviceName, idSuffix)
 }
 
 func makeProxySvcIDAndName(serviceID, serviceName string) (string, string) {
@@ -418,6 +1208,24 @@ func makeProxySvcIDAndName(serviceID, serviceName string) (string, string) {
 	return fmt.Sprintf(fmtStr, serviceID), fmt.Sprintf(fmtStr, serviceName)
 }
 
+// addLANIPTaggedAddresses adds the lan_ipv4 and lan_ipv6 tagged addresses for
+// dual-stack tasks, so that they are reachable over both IP versions. Tasks
+// without an IPv6 address are unchanged. The primary address is not changed.
+func addLANIPTaggedAddresses(taggedAddresses map[string]api.ServiceAddress, taskMeta awsutil.ECSTaskMeta, port int) map[string]api.ServiceAddress {
+	ipv6 := taskMeta.NodeIPv6()
+	if ipv6 == "" {
+		return taggedAddresses
+	}
+	if taggedAddresses == nil {
+		taggedAddresses = make(map[string]api.ServiceAddress)
+	}
+	taggedAddresses[config.TaggedAddressLANIPv6] = api.ServiceAddress{Address: ipv6, Port: port}
+	if ipv4 := taskMeta.NodeIPv4(); ipv4 != "" {
+		taggedAddresses[config.TaggedAddressLANIPv4] = api.ServiceAddress{Address: ipv4, Port: port}
+	}
+	return taggedAddresses
+}
+
 func mergeMeta(m1, m2 map[string]string) map[string]string {
 	result := make(map[string]string)
 
@@ -433,7 +1241,7 @@ func mergeMeta(m1, m2 map[string]string) map[string]string {
 }
 
 func getLocalityParams(taskMeta awsutil.ECSTaskMeta) *api.Locality {
-	region := awsutil.GetAWSRegion()
+	region := awsutil.GetAWSRegion(taskMeta)
 	zone := taskMeta.AvailabilityZone
 
 	if region == "" {
@@ -445,3 +1253,27 @@ func getLocalityParams(taskMeta awsutil.ECSTaskMeta) *api.Locality {
 		Zone:   zone,
 	}
 }
+
+// appendLocalityTags returns the tags with `region:<region>` and `az:<zone>`
+// tags added for the locality. Duplicate tags are removed.
+func appendLocalityTags(tags []string, locality *api.Locality) []string {
+	if locality == nil {
+		return tags
+	}
+
+	allTags := append([]string{}, tags...)
+	allTags = append(allTags, "region:"+locality.Region)
+	if locality.Zone != "" {
+		allTags = append(allTags, "az:"+locality.Zone)
+	}
+
+	result := make([]string, 0, len(allTags))
+	seen := make(map[string]bool, len(allTags))
+	for _, tag := range allTags {
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result
+}
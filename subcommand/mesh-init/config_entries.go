@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshinit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/consul/api"
+)
+
+// maxProxyDefaultsAttempts bounds the check-and-set attempts when other tasks
+// write the proxy-defaults config entry concurrently.
+const maxProxyDefaultsAttempts = 5
+
+// writeGatewayConfigEntry writes the config entry for the gateway, if the
+// gateway kind is configured through one and the config defines its contents.
+// Gateways without a config entry in the config are left untouched, so config
+// entries managed outside of consul-ecs are not overwritten. Failed writes are
+// retried like registrations until they succeed or ctx is done.
+func (c *Command) writeGatewayConfigEntry(ctx context.Context, consulClient *api.Client, gatewayRegistration *api.CatalogRegistration) error {
+	entry := c.constructGatewayConfigEntry(gatewayRegistration.Service)
+	if entry == nil {
+		return nil
+	}
+
+	opts := (&api.WriteOptions{}).WithContext(ctx)
+	err := backoff.RetryNotify(func() error {
+		_, _, err := consulClient.ConfigEntries().Set(entry, opts)
+		if err != nil && !isRetryableRegistrationError(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(c.registrationBackOff(), ctx), retryLogger(c.log))
+	if err != nil {
+		return fmt.Errorf("writing %s config entry %s: %w", entry.GetKind(), entry.GetName(), err)
+	}
+	c.log.Info("wrote config entry", "kind", entry.GetKind(), "name", entry.GetName())
+	return nil
+}
+
+func (c *Command) constructGatewayConfigEntry(gatewaySvc *api.AgentService) api.ConfigEntry {
+	switch c.config.Gateway.Kind {
+	case api.ServiceKindIngressGateway:
+		if len(c.config.Gateway.Listeners) == 0 {
+			return nil
+		}
+		entry := &api.IngressGatewayConfigEntry{
+			Kind:      api.IngressGateway,
+			Name:      gatewaySvc.Service,
+			Namespace: gatewaySvc.Namespace,
+			Partition: gatewaySvc.Partition,
+		}
+		for _, listener := range c.config.Gateway.Listeners {
+			entry.Listeners = append(entry.Listeners, listener.ToConsulType())
+		}
+		return entry
+	case api.ServiceKindTerminatingGateway:
+		if len(c.config.Gateway.LinkedServices) == 0 {
+			return nil
+		}
+		entry := &api.TerminatingGatewayConfigEntry{
+			Kind:      api.TerminatingGateway,
+			Name:      gatewaySvc.Service,
+			Namespace: gatewaySvc.Namespace,
+			Partition: gatewaySvc.Partition,
+		}
+		for _, svc := range c.config.Gateway.LinkedServices {
+			entry.Services = append(entry.Services, svc.ToConsulType())
+		}
+		return entry
+	}
+	return nil
+}
+
+// writeProxyDefaults sets the mesh gateway mode in the global proxy-defaults
+// config entry of the partition, when mesh.writeProxyDefaults is set. The
+// entry is written with a check-and-set, so the other fields of an existing
+// entry are kept, and is retried if another task modified it in the meantime.
+// An entry that already sets a mesh gateway mode is left untouched.
+func (c *Command) writeProxyDefaults(ctx context.Context, consulClient *api.Client, partition string) error {
+	defaults := c.config.Mesh.WriteProxyDefaults
+	if defaults == nil {
+		return nil
+	}
+	mode := defaults.MeshGateway.Mode
+
+	return backoff.RetryNotify(func() error {
+		existing, _, err := consulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal,
+			(&api.QueryOptions{Partition: partition}).WithContext(ctx))
+		if err != nil && !isNotFoundError(err) {
+			return backoff.Permanent(fmt.Errorf("reading %s config entry: %w", api.ProxyDefaults, err))
+		}
+
+		entry, found := existing.(*api.ProxyConfigEntry)
+		if !found {
+			// A check-and-set with the zero index only succeeds while the entry does not exist.
+			entry = &api.ProxyConfigEntry{
+				Kind:      api.ProxyDefaults,
+				Name:      api.ProxyConfigGlobal,
+				Partition: partition,
+			}
+		} else if entry.MeshGateway.Mode != api.MeshGatewayModeDefault {
+			if entry.MeshGateway.Mode != mode {
+				c.log.Warn("proxy-defaults config entry already sets a different mesh gateway mode, leaving it unchanged",
+					"mode", entry.MeshGateway.Mode)
+			}
+			return nil
+		}
+
+		entry.MeshGateway.Mode = mode
+		ok, _, err := consulClient.ConfigEntries().CAS(entry, entry.ModifyIndex,
+			(&api.WriteOptions{Partition: partition}).WithContext(ctx))
+		if err != nil {
+			return backoff.Permanent(fmt.Errorf("writing %s config entry: %w", api.ProxyDefaults, err))
+		}
+		if !ok {
+			return fmt.Errorf("%s config entry was modified concurrently", api.ProxyDefaults)
+		}
+		c.log.Info("wrote config entry", "kind", api.ProxyDefaults, "name", api.ProxyConfigGlobal, "mode", mode)
+		return nil
+	}, backoff.WithContext(backoff.WithMaxRetries(backoff.NewConstantBackOff(1*time.Second), maxProxyDefaultsAttempts-1), ctx),
+		retryLogger(c.log))
+}
+
+// isNotFoundError returns true if Consul responded with a 404.
+func isNotFoundError(err error) bool {
+	var statusErr api.StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound
+}
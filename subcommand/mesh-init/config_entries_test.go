@@ -0,0 +1,345 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshinit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-ecs/config"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteGatewayConfigEntry(t *testing.T) {
+	gatewaySvc := &api.AgentService{
+		ID:      "gateway-abcdef",
+		Service: "gateway",
+	}
+
+	cases := map[string]struct {
+		gateway  *config.GatewayRegistration
+		expEntry api.ConfigEntry
+	}{
+		"mesh gateway": {
+			gateway: &config.GatewayRegistration{Kind: api.ServiceKindMeshGateway},
+		},
+		"ingress gateway without listeners": {
+			gateway: &config.GatewayRegistration{Kind: api.ServiceKindIngressGateway},
+		},
+		"ingress gateway with listeners": {
+			gateway: &config.GatewayRegistration{
+				Kind: api.ServiceKindIngressGateway,
+				Listeners: []config.IngressListener{
+					{
+						Port:     8080,
+						Protocol: "http",
+						Services: []config.IngressService{
+							{Name: "frontend", Hosts: []string{"frontend.example.com"}},
+							{Name: "backend"},
+						},
+					},
+					{
+						Port:     9090,
+						Services: []config.IngressService{{Name: "db"}},
+					},
+				},
+			},
+			expEntry: &api.IngressGatewayConfigEntry{
+				Kind: api.IngressGateway,
+				Name: "gateway",
+				Listeners: []api.IngressListener{
+					{
+						Port:     8080,
+						Protocol: "http",
+						Services: []api.IngressService{
+							{Name: "frontend", Hosts: []string{"frontend.example.com"}},
+							{Name: "backend"},
+						},
+					},
+					{
+						Port:     9090,
+						Services: []api.IngressService{{Name: "db"}},
+					},
+				},
+			},
+		},
+		"terminating gateway without linked services": {
+			gateway: &config.GatewayRegistration{Kind: api.ServiceKindTerminatingGateway},
+		},
+		"terminating gateway with linked services": {
+			gateway: &config.GatewayRegistration{
+				Kind: api.ServiceKindTerminatingGateway,
+				LinkedServices: []config.LinkedService{
+					{Name: "billing", CAFile: "/certs/ca.pem", SNI: "billing.example.com"},
+					{Name: "payments", CAFile: "/certs/ca.pem", CertFile: "/certs/cert.pem", KeyFile: "/certs/key.pem"},
+					{Name: "legacy"},
+				},
+			},
+			expEntry: &api.TerminatingGatewayConfigEntry{
+				Kind: api.TerminatingGateway,
+				Name: "gateway",
+				Services: []api.LinkedService{
+					{Name: "billing", CAFile: "/certs/ca.pem", SNI: "billing.example.com"},
+					{Name: "payments", CAFile: "/certs/ca.pem", CertFile: "/certs/cert.pem", KeyFile: "/certs/key.pem"},
+					{Name: "legacy"},
+				},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var written []byte
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPut, r.Method)
+				require.Equal(t, "/v1/config", r.URL.Path)
+				written = readBody(t, r)
+				fmt.Fprint(w, "true")
+			}))
+			t.Cleanup(consulServer.Close)
+
+			consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			cmd := Command{
+				log:    hclog.NewNullLogger(),
+				config: &config.Config{Gateway: c.gateway},
+			}
+			err = cmd.writeGatewayConfigEntry(context.Background(), consulClient, &api.CatalogRegistration{Service: gatewaySvc})
+			require.NoError(t, err)
+
+			if c.expEntry == nil {
+				require.Nil(t, written)
+				return
+			}
+			entry, err := api.DecodeConfigEntryFromJSON(written)
+			require.NoError(t, err)
+			require.Equal(t, c.expEntry, entry)
+		})
+	}
+}
+
+func TestWriteGatewayConfigEntry_Retry(t *testing.T) {
+	cases := map[string]struct {
+		failures   int
+		failStatus int
+		expErr     string
+		expWrites  int
+	}{
+		"retries server errors": {
+			failures:   2,
+			failStatus: http.StatusInternalServerError,
+			expWrites:  3,
+		},
+		"does not retry client errors": {
+			failures:   1,
+			failStatus: http.StatusBadRequest,
+			expErr:     "writing terminating-gateway config entry gateway: Unexpected response code: 400 (invalid entry)",
+			expWrites:  1,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			writes := 0
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writes++
+				if writes <= c.failures {
+					w.WriteHeader(c.failStatus)
+					fmt.Fprint(w, "invalid entry")
+					return
+				}
+				fmt.Fprint(w, "true")
+			}))
+			t.Cleanup(consulServer.Close)
+
+			consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			cmd := Command{
+				log: hclog.NewNullLogger(),
+				config: &config.Config{
+					Gateway: &config.GatewayRegistration{
+						Kind:           api.ServiceKindTerminatingGateway,
+						LinkedServices: []config.LinkedService{{Name: "legacy"}},
+					},
+					Mesh: config.Mesh{Retry: &config.Retry{InitialInterval: config.Duration(10 * time.Millisecond)}},
+				},
+			}
+			err = cmd.writeGatewayConfigEntry(context.Background(), consulClient,
+				&api.CatalogRegistration{Service: &api.AgentService{ID: "gateway-abcdef", Service: "gateway"}})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.expWrites, writes)
+		})
+	}
+}
+
+func TestWriteProxyDefaults(t *testing.T) {
+	cases := map[string]struct {
+		proxyDefaults *config.ProxyDefaults
+		existing      *api.ProxyConfigEntry
+		concurrent    bool
+		expEntry      *api.ProxyConfigEntry
+		expWrites     int
+	}{
+		"disabled": {},
+		"creates the entry": {
+			proxyDefaults: &config.ProxyDefaults{MeshGateway: config.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal}},
+			expEntry: &api.ProxyConfigEntry{
+				Kind:        api.ProxyDefaults,
+				Name:        api.ProxyConfigGlobal,
+				MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal},
+				ModifyIndex: 1,
+			},
+			expWrites: 1,
+		},
+		"keeps the fields of an existing entry": {
+			proxyDefaults: &config.ProxyDefaults{MeshGateway: config.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal}},
+			existing: &api.ProxyConfigEntry{
+				Kind:   api.ProxyDefaults,
+				Name:   api.ProxyConfigGlobal,
+				Config: map[string]interface{}{"protocol": "http"},
+			},
+			expEntry: &api.ProxyConfigEntry{
+				Kind:        api.ProxyDefaults,
+				Name:        api.ProxyConfigGlobal,
+				Config:      map[string]interface{}{"protocol": "http"},
+				MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal},
+				ModifyIndex: 2,
+			},
+			expWrites: 1,
+		},
+		"does not overwrite an existing mode": {
+			proxyDefaults: &config.ProxyDefaults{MeshGateway: config.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal}},
+			existing: &api.ProxyConfigEntry{
+				Kind:        api.ProxyDefaults,
+				Name:        api.ProxyConfigGlobal,
+				MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeRemote},
+			},
+			expEntry: &api.ProxyConfigEntry{
+				Kind:        api.ProxyDefaults,
+				Name:        api.ProxyConfigGlobal,
+				MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeRemote},
+				ModifyIndex: 1,
+			},
+		},
+		"retries after a concurrent write": {
+			proxyDefaults: &config.ProxyDefaults{MeshGateway: config.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal}},
+			concurrent:    true,
+			expEntry: &api.ProxyConfigEntry{
+				Kind:        api.ProxyDefaults,
+				Name:        api.ProxyConfigGlobal,
+				Config:      map[string]interface{}{"protocol": "http"},
+				MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal},
+				ModifyIndex: 2,
+			},
+			expWrites: 1,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			// The fake server stores a single proxy-defaults entry and
+			// implements check-and-set against its modify index.
+			stored := c.existing
+			var index uint64
+			if stored != nil {
+				index++
+				stored.ModifyIndex = index
+			}
+			concurrent := c.concurrent
+			var writes int
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/v1/config/proxy-defaults/global":
+					if stored == nil {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					require.NoError(t, json.NewEncoder(w).Encode(stored))
+				case r.Method == http.MethodPut && r.URL.Path == "/v1/config":
+					if concurrent {
+						// Another task creates the entry between the read and the write.
+						concurrent = false
+						index++
+						stored = &api.ProxyConfigEntry{
+							Kind:        api.ProxyDefaults,
+							Name:        api.ProxyConfigGlobal,
+							Config:      map[string]interface{}{"protocol": "http"},
+							ModifyIndex: index,
+						}
+					}
+					cas, err := strconv.ParseUint(r.URL.Query().Get("cas"), 10, 64)
+					require.NoError(t, err)
+					if cas != index {
+						fmt.Fprint(w, "false")
+						return
+					}
+					entry, err := api.DecodeConfigEntryFromJSON(readBody(t, r))
+					require.NoError(t, err)
+					index++
+					stored = entry.(*api.ProxyConfigEntry)
+					stored.ModifyIndex = index
+					writes++
+					fmt.Fprint(w, "true")
+				default:
+					t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+				}
+			}))
+			t.Cleanup(consulServer.Close)
+
+			consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			cmd := Command{
+				log:    hclog.NewNullLogger(),
+				config: &config.Config{Mesh: config.Mesh{WriteProxyDefaults: c.proxyDefaults}},
+			}
+			// The second run, such as from another task, finds the mode set and does not write.
+			for i := 0; i < 2; i++ {
+				require.NoError(t, cmd.writeProxyDefaults(context.Background(), consulClient, ""))
+			}
+			require.Equal(t, c.expWrites, writes)
+			require.Equal(t, c.expEntry, stored)
+		})
+	}
+}
+
+func TestWriteProxyDefaults_ContextDone(t *testing.T) {
+	requests := 0
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	t.Cleanup(consulServer.Close)
+
+	consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	cmd := Command{
+		log: hclog.NewNullLogger(),
+		config: &config.Config{Mesh: config.Mesh{
+			WriteProxyDefaults: &config.ProxyDefaults{MeshGateway: config.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal}},
+		}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = cmd.writeProxyDefaults(ctx, consulClient, "")
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 0, requests)
+}
+
+func readBody(t *testing.T, r *http.Request) []byte {
+	var body json.RawMessage
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	return body
+}
@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshinit
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/consul-ecs/awsutil"
+	"github.com/hashicorp/consul/api"
+)
+
+// DryRunOutput is printed by `mesh-init -dry-run`. It contains everything
+// mesh-init would register with Consul and write to the bootstrap directory.
+type DryRunOutput struct {
+	// ServiceRegistration is empty for gateways, which only register a proxy.
+	ServiceRegistration *api.CatalogRegistration `json:"serviceRegistration,omitempty"`
+	// ProxyRegistration and DataplaneConfig are empty when the sidecar is disabled.
+	ProxyRegistration *api.CatalogRegistration `json:"proxyRegistration,omitempty"`
+	ConfigEntry       api.ConfigEntry          `json:"configEntry,omitempty"`
+	DataplaneConfig   json.RawMessage          `json:"dataplaneConfig,omitempty"`
+}
+
+// dryRun constructs the registrations and the dataplane config and prints
+// them to stdout without contacting Consul or writing any files.
+//
+// The Consul edition cannot be checked, so the -partition and -namespace flags
+// are applied as given. The gateway public IP is not looked up and login
+// credentials are left out of the dataplane config, since they require AWS API calls.
+func (c *Command) dryRun(taskMeta awsutil.ECSTaskMeta, nodeName string) error {
+	c.setTenancyOverrides()
+
+	var output DryRunOutput
+	if c.config.IsGateway() {
+		output.ProxyRegistration = c.constructGatewayProxyRegistration(taskMeta, nodeName)
+		output.ConfigEntry = c.constructGatewayConfigEntry(output.ProxyRegistration.Service)
+	} else {
+		output.ServiceRegistration = c.constructServiceRegistration(taskMeta, nodeName)
+		if c.config.SidecarEnabled() {
+			output.ProxyRegistration = c.constructProxyRegistration(output.ServiceRegistration, taskMeta, nodeName)
+		}
+	}
+
+	if output.ProxyRegistration != nil {
+		caCertPath, _ := c.rpcCACert()
+		dataplaneConfig, err := c.generateDataplaneConfig(output.ProxyRegistration, nil, caCertPath)
+		if err != nil {
+			return err
+		}
+		output.DataplaneConfig = dataplaneConfig
+	}
+
+	outputJSON, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return err
+	}
+	c.UI.Output(string(outputJSON))
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshinit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/consul-ecs/awsutil"
+	"github.com/hashicorp/consul-ecs/config"
+	"github.com/hashicorp/consul-ecs/testutil"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	var consulRequests int32
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&consulRequests, 1)
+	}))
+	t.Cleanup(consulServer.Close)
+	_, consulPort := testutil.GetHostAndPortFromAddress(consulServer.Listener.Addr().String())
+
+	taskMetaRespStr, err := constructTaskMetaResponseString(&awsutil.ECSTaskMeta{
+		Cluster: "test",
+		TaskARN: "arn:aws:ecs:us-east-1:123456789:task/test/abcdef",
+		Family:  "family-service",
+	})
+	require.NoError(t, err)
+	testutil.TaskMetaServer(t, testutil.TaskMetaHandler(t, taskMetaRespStr))
+
+	bootstrapDir := testutil.TempDir(t)
+	testutil.SetECSConfigEnvVar(t, &config.Config{
+		BootstrapDir: bootstrapDir,
+		ConsulServers: config.ConsulServers{
+			Hosts:           "127.0.0.1",
+			GRPC:            config.GRPCSettings{Port: consulPort},
+			HTTP:            config.HTTPSettings{Port: consulPort},
+			SkipServerWatch: true,
+		},
+		Proxy: &config.AgentServiceConnectProxyConfig{},
+		Service: config.ServiceRegistration{
+			Name: "test-service",
+			Port: 8080,
+		},
+	})
+
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui}
+	code := cmd.Run([]string{"-dry-run"})
+	require.Equal(t, 0, code, ui.ErrorWriter.String())
+
+	require.Zero(t, atomic.LoadInt32(&consulRequests))
+	files, err := os.ReadDir(bootstrapDir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	var output struct {
+		ServiceRegistration struct {
+			Service struct{ ID, Service string }
+		} `json:"serviceRegistration"`
+		ProxyRegistration struct {
+			Service struct{ ID, Service string }
+		} `json:"proxyRegistration"`
+		DataplaneConfig map[string]interface{} `json:"dataplaneConfig"`
+	}
+	require.NoError(t, json.Unmarshal(ui.OutputWriter.Bytes(), &output))
+	require.Equal(t, "test-service", output.ServiceRegistration.Service.Service)
+	require.Equal(t, "test-service-abcdef", output.ServiceRegistration.Service.ID)
+	require.Equal(t, "test-service-sidecar-proxy", output.ProxyRegistration.Service.Service)
+	require.Equal(t, "test-service-abcdef-sidecar-proxy", output.ProxyRegistration.Service.ID)
+	require.NotEmpty(t, output.DataplaneConfig)
+}
+
+func TestDryRunWithoutSidecar(t *testing.T) {
+	cases := map[string]struct {
+		mesh          config.Mesh
+		connectNative bool
+	}{
+		"sidecar disabled": {
+			mesh: config.Mesh{
+				Sidecar: &config.Sidecar{Enabled: testutil.BoolPtr(false)},
+			},
+		},
+		"connect native": {
+			connectNative: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			taskMetaRespStr, err := constructTaskMetaResponseString(&awsutil.ECSTaskMeta{
+				Cluster: "test",
+				TaskARN: "arn:aws:ecs:us-east-1:123456789:task/test/abcdef",
+				Family:  "family-service",
+			})
+			require.NoError(t, err)
+			testutil.TaskMetaServer(t, testutil.TaskMetaHandler(t, taskMetaRespStr))
+
+			testutil.SetECSConfigEnvVar(t, &config.Config{
+				BootstrapDir:         testutil.TempDir(t),
+				HealthSyncContainers: []string{"app"},
+				ConsulServers: config.ConsulServers{
+					Hosts:           "127.0.0.1",
+					SkipServerWatch: true,
+				},
+				Mesh:  c.mesh,
+				Proxy: &config.AgentServiceConnectProxyConfig{},
+				Service: config.ServiceRegistration{
+					Name:          "test-service",
+					Port:          8080,
+					ConnectNative: c.connectNative,
+				},
+			})
+
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			code := cmd.Run([]string{"-dry-run"})
+			require.Equal(t, 0, code, ui.ErrorWriter.String())
+
+			var output DryRunOutput
+			require.NoError(t, json.Unmarshal(ui.OutputWriter.Bytes(), &output))
+			require.Equal(t, "test-service-abcdef", output.ServiceRegistration.Service.ID)
+			require.Nil(t, output.ProxyRegistration)
+			require.Nil(t, output.DataplaneConfig)
+
+			if c.connectNative {
+				require.NotNil(t, output.ServiceRegistration.Service.Connect)
+				require.True(t, output.ServiceRegistration.Service.Connect.Native)
+			} else {
+				require.Nil(t, output.ServiceRegistration.Service.Connect)
+			}
+
+			// Only the health-sync check is registered, without the dataplane readiness check.
+			require.Len(t, output.ServiceRegistration.Checks, 1)
+			require.Equal(t, "test-service-abcdef-app", output.ServiceRegistration.Checks[0].CheckID)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshinit
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/hashicorp/consul-ecs/awsutil"
+	"github.com/hashicorp/consul-ecs/config"
+)
+
+const (
+	eniAttachmentType      = "ElasticNetworkInterface"
+	eniAttachmentDetailKey = "networkInterfaceId"
+)
+
+// setGatewayPublicWanAddress uses the public IP of the task as the gateway's
+// WAN address if `gateway.assignPublicIP` is set and no WAN address is configured.
+func (c *Command) setGatewayPublicWanAddress(taskMeta awsutil.ECSTaskMeta, clusterARN string) error {
+	gateway := c.config.Gateway
+	if !gateway.AssignPublicIP || (gateway.WanAddress != nil && gateway.WanAddress.Address != "") {
+		return nil
+	}
+
+	clientSession, err := awsutil.NewSession(taskMeta, "mesh-init")
+	if err != nil {
+		return err
+	}
+
+	publicIP, err := taskPublicIP(ecs.New(clientSession), ec2.New(clientSession), taskMeta.TaskARN, clusterARN)
+	if err != nil {
+		return fmt.Errorf("resolving the public IP for the gateway WAN address: %w", err)
+	}
+
+	if gateway.WanAddress == nil {
+		gateway.WanAddress = &config.GatewayAddress{}
+	}
+	gateway.WanAddress.Address = publicIP
+	c.log.Info("using the task public IP as the gateway WAN address", "address", publicIP)
+	return nil
+}
+
+// taskPublicIP returns the public IP associated with the elastic network
+// interface of the task. This requires the task to use the awsvpc network mode.
+func taskPublicIP(ecsClient ecsiface.ECSAPI, ec2Client ec2iface.EC2API, taskARN, clusterARN string) (string, error) {
+	tasks, err := ecsClient.DescribeTasks(&ecs.DescribeTasksInput{
+		Cluster: aws.String(clusterARN),
+		Tasks:   []*string{aws.String(taskARN)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("describing task %s: %w", taskARN, err)
+	}
+	if len(tasks.Tasks) == 0 {
+		return "", fmt.Errorf("task %s not found", taskARN)
+	}
+
+	var eniID string
+	for _, attachment := range tasks.Tasks[0].Attachments {
+		if aws.StringValue(attachment.Type) != eniAttachmentType {
+			continue
+		}
+		for _, detail := range attachment.Details {
+			if aws.StringValue(detail.Name) == eniAttachmentDetailKey {
+				eniID = aws.StringValue(detail.Value)
+			}
+		}
+	}
+	if eniID == "" {
+		return "", fmt.Errorf("no network interface is attached to task %s", taskARN)
+	}
+
+	interfaces, err := ec2Client.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String(eniID)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("describing network interface %s: %w", eniID, err)
+	}
+	for _, eni := range interfaces.NetworkInterfaces {
+		if eni.Association != nil && aws.StringValue(eni.Association.PublicIp) != "" {
+			return aws.StringValue(eni.Association.PublicIp), nil
+		}
+	}
+	return "", fmt.Errorf("no public IP is associated with network interface %s", eniID)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshinit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/hashicorp/consul-ecs/controller/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEC2Client struct {
+	ec2iface.EC2API
+	publicIPs map[string]string
+}
+
+func (f *fakeEC2Client) DescribeNetworkInterfaces(input *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	var result []*ec2.NetworkInterface
+	for _, id := range input.NetworkInterfaceIds {
+		publicIP, ok := f.publicIPs[*id]
+		if !ok {
+			return nil, fmt.Errorf("network interface %s not found", *id)
+		}
+		eni := &ec2.NetworkInterface{NetworkInterfaceId: id}
+		if publicIP != "" {
+			eni.Association = &ec2.NetworkInterfaceAssociation{PublicIp: aws.String(publicIP)}
+		}
+		result = append(result, eni)
+	}
+	return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: result}, nil
+}
+
+func TestTaskPublicIP(t *testing.T) {
+	const (
+		clusterARN = "arn:aws:ecs:us-east-1:123456789:cluster/test"
+		taskARN    = "arn:aws:ecs:us-east-1:123456789:task/test/abcdef"
+	)
+	eniAttachment := func(eniID string) []*ecs.Attachment {
+		return []*ecs.Attachment{
+			{
+				Type: aws.String(eniAttachmentType),
+				Details: []*ecs.KeyValuePair{
+					{Name: aws.String("subnetId"), Value: aws.String("subnet-1234")},
+					{Name: aws.String(eniAttachmentDetailKey), Value: aws.String(eniID)},
+				},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		tasks       []*ecs.Task
+		publicIPs   map[string]string
+		expPublicIP string
+		expError    string
+	}{
+		"public IP from the task ENI": {
+			tasks:       []*ecs.Task{{TaskArn: aws.String(taskARN), Attachments: eniAttachment("eni-1234")}},
+			publicIPs:   map[string]string{"eni-1234": "255.1.2.3"},
+			expPublicIP: "255.1.2.3",
+		},
+		"task not found": {
+			expError: fmt.Sprintf("task %s not found", taskARN),
+		},
+		"task without an ENI": {
+			tasks:    []*ecs.Task{{TaskArn: aws.String(taskARN)}},
+			expError: fmt.Sprintf("no network interface is attached to task %s", taskARN),
+		},
+		"ENI without a public IP": {
+			tasks:     []*ecs.Task{{TaskArn: aws.String(taskARN), Attachments: eniAttachment("eni-1234")}},
+			publicIPs: map[string]string{"eni-1234": ""},
+			expError:  "no public IP is associated with network interface eni-1234",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ecsClient := &mocks.ECSClient{Tasks: c.tasks}
+			ec2Client := &fakeEC2Client{publicIPs: c.publicIPs}
+
+			publicIP, err := taskPublicIP(ecsClient, ec2Client, taskARN, clusterARN)
+			if c.expError != "" {
+				require.EqualError(t, err, c.expError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expPublicIP, publicIP)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshinit
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+
+	"github.com/hashicorp/consul-ecs/config"
+	"github.com/hashicorp/consul/api"
+)
+
+const summaryFileName = "mesh-init-summary.json"
+
+// Summary describes what mesh-init registered and wrote for the task.
+// It is written to the bootstrap directory to help with debugging.
+type Summary struct {
+	// ServiceID is empty for gateways, which only register a proxy.
+	ServiceID string `json:"serviceID,omitempty"`
+	// ProxyServiceID and DataplaneConfigPath are empty when the sidecar is disabled.
+	ProxyServiceID      string `json:"proxyServiceID,omitempty"`
+	NodeName            string `json:"nodeName"`
+	Partition           string `json:"partition,omitempty"`
+	Namespace           string `json:"namespace,omitempty"`
+	DataplaneConfigPath string `json:"dataplaneConfigPath,omitempty"`
+	CACertPath          string `json:"caCertPath,omitempty"`
+
+	// LanAddress and WanAddress are the resolved addresses of a mesh gateway,
+	// for configuring WAN federation. WanAddress is absent when no WAN address
+	// is configured.
+	LanAddress *api.ServiceAddress `json:"lanAddress,omitempty"`
+	WanAddress *api.ServiceAddress `json:"wanAddress,omitempty"`
+}
+
+// RegistrationOutput is printed to stdout by `mesh-init -format json` on success.
+type RegistrationOutput struct {
+	// ServiceID is empty for gateways, which only register a proxy.
+	ServiceID string `json:"serviceID,omitempty"`
+	// ProxyServiceID is empty when the sidecar is disabled.
+	ProxyServiceID string `json:"proxyServiceID,omitempty"`
+	NodeName       string `json:"nodeName"`
+}
+
+// outputRegistration prints the registered IDs and node as a single line of JSON
+// to stdout, so that it can be parsed separately from the logs on stderr.
+func (c *Command) outputRegistration(serviceRegistration, proxyRegistration *api.CatalogRegistration) error {
+	var output RegistrationOutput
+	if serviceRegistration != nil {
+		output.ServiceID = serviceRegistration.Service.ID
+		output.NodeName = serviceRegistration.Node
+	}
+	if proxyRegistration != nil {
+		output.ProxyServiceID = proxyRegistration.Service.ID
+		output.NodeName = proxyRegistration.Node
+	}
+
+	outputJSON, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+	c.UI.Output(string(outputJSON))
+	return nil
+}
+
+// writeSummary writes the summary of the registrations and generated
+// files to a shared volume.
+func (c *Command) writeSummary(serviceRegistration, proxyRegistration *api.CatalogRegistration, caCertFilePath string) error {
+	summary := Summary{
+		CACertPath: caCertFilePath,
+	}
+	if serviceRegistration != nil {
+		summary.ServiceID = serviceRegistration.Service.ID
+		summary.NodeName = serviceRegistration.Node
+		summary.Partition = serviceRegistration.Service.Partition
+		summary.Namespace = serviceRegistration.Service.Namespace
+	}
+	if proxyRegistration != nil {
+		summary.ProxyServiceID = proxyRegistration.Service.ID
+		summary.NodeName = proxyRegistration.Node
+		summary.Partition = proxyRegistration.Service.Partition
+		summary.Namespace = proxyRegistration.Service.Namespace
+		summary.DataplaneConfigPath = path.Join(c.config.BootstrapDir, dataplaneConfigFileName)
+		if proxyRegistration.Service.Kind == api.ServiceKindMeshGateway {
+			summary.LanAddress, summary.WanAddress = meshGatewayAddresses(proxyRegistration.Service)
+		}
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	summaryPath := path.Join(c.config.BootstrapDir, summaryFileName)
+	err = os.WriteFile(summaryPath, summaryJSON, c.config.Mesh.GetBootstrapFileMode())
+	if err != nil {
+		return err
+	}
+	c.log.Info("wrote mesh-init summary", "path", summaryPath)
+	return nil
+}
+
+// meshGatewayAddresses returns the LAN and WAN addresses that the mesh gateway
+// is reachable on. The LAN address falls back to the service address and port
+// when no LAN tagged address is registered. The WAN address is nil when no WAN
+// tagged address is registered.
+func meshGatewayAddresses(gatewaySvc *api.AgentService) (lan, wan *api.ServiceAddress) {
+	lan = &api.ServiceAddress{Address: gatewaySvc.Address, Port: gatewaySvc.Port}
+	if addr, ok := gatewaySvc.TaggedAddresses[config.TaggedAddressLAN]; ok {
+		lan = &addr
+	}
+	if addr, ok := gatewaySvc.TaggedAddresses[config.TaggedAddressWAN]; ok {
+		wan = &addr
+	}
+	return lan, wan
+}
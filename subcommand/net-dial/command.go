@@ -4,39 +4,91 @@
 package netdial
 
 import (
+	"flag"
+	"fmt"
 	"net"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/mitchellh/cli"
 )
 
+const (
+	flagTimeout       = "timeout"
+	flagRetryInterval = "retry-interval"
+
+	defaultTimeout = 5 * time.Second
+)
+
 type Command struct {
 	UI cli.Ui
+
+	flagSet           *flag.FlagSet
+	flagTimeout       time.Duration
+	flagRetryInterval time.Duration
+	once              sync.Once
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.DurationVar(&c.flagTimeout, flagTimeout, defaultTimeout,
+		"Maximum time to spend connecting, including retries.")
+	c.flagSet.DurationVar(&c.flagRetryInterval, flagRetryInterval, 0,
+		"Time to wait between connection attempts. If zero, the connection is attempted only once.")
 }
 
 func (c *Command) Run(args []string) int {
-	if len(args) != 1 {
+	c.once.Do(c.init)
+
+	if err := c.flagSet.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("invalid flags: %s", err))
+		return 1
+	}
+
+	if len(c.flagSet.Args()) != 1 {
 		c.UI.Error("invalid invocation, expected one positional argument: <host>:<port>")
 		return 1
 	}
 
-	conn, err := net.Dial("tcp", args[0])
-	if err != nil {
+	if !c.dial(c.flagSet.Arg(0)) {
 		return 2
 	}
-	conn.Close()
-
 	return 0
 }
 
+// dial attempts to open a TCP connection to addr until it succeeds or the
+// timeout is reached, waiting the retry interval between attempts.
+func (c *Command) dial(addr string) bool {
+	deadline := time.Now().Add(c.flagTimeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Until(deadline))
+		if err == nil {
+			conn.Close()
+			return true
+		}
+		if c.flagRetryInterval <= 0 || time.Until(deadline) <= c.flagRetryInterval {
+			return false
+		}
+		time.Sleep(c.flagRetryInterval)
+	}
+}
+
 func (c *Command) Synopsis() string {
 	return "Checks for a TCP listener on a host"
 }
 
 func (c *Command) Help() string {
-	return `usage: consul-ecs net-dial <host>:<port>
+	c.once.Do(c.init)
+
+	var buf strings.Builder
+	c.flagSet.SetOutput(&buf)
+	c.flagSet.PrintDefaults()
+	return `usage: consul-ecs net-dial [options] <host>:<port>
 
 Attempts to open a TCP connection to <host>:<port>.
 An exit code of 0 is returned if the connection succeeds.
 A non zero exit code is returned if the connection fails for any reason.
-`
+
+` + buf.String()
 }
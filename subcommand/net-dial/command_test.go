@@ -6,6 +6,7 @@ package netdial
 import (
 	"net"
 	"testing"
+	"time"
 
 	"github.com/mitchellh/cli"
 	"github.com/stretchr/testify/require"
@@ -14,19 +15,36 @@ import (
 func TestNetDial(t *testing.T) {
 	cases := map[string]struct {
 		host   string
+		flags  []string
 		code   int
 		errStr string
 	}{
 		"success":              {host: "localhost", code: 0},
 		"failure no listener":  {host: "localhost", code: 2},
-		"failure invalid args": {code: 1},
+		"failure invalid args": {code: 1, errStr: "expected one positional argument"},
+		"failure invalid flags": {
+			host:   "localhost",
+			flags:  []string{"-timeout", "invalid"},
+			code:   1,
+			errStr: "invalid flags",
+		},
+		"success with retries": {
+			host:  "localhost",
+			flags: []string{"-timeout", "1s", "-retry-interval", "100ms"},
+			code:  0,
+		},
+		"failure after retries": {
+			host:  "localhost",
+			flags: []string{"-timeout", "300ms", "-retry-interval", "100ms"},
+			code:  2,
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
 			ui := cli.NewMockUi()
 			cmd := Command{UI: ui}
 
-			var args []string
+			args := c.flags
 
 			if c.host != "" {
 				l, err := net.Listen("tcp", c.host+":")
@@ -40,6 +58,32 @@ func TestNetDial(t *testing.T) {
 			}
 
 			require.Equal(t, c.code, cmd.Run(args))
+			if c.errStr != "" {
+				require.Contains(t, ui.ErrorWriter.String(), c.errStr)
+			}
 		})
 	}
 }
+
+func TestNetDialRetriesUntilListening(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	// Start listening on the port after the first connection attempt fails.
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { l.Close() })
+	}()
+
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui}
+	start := time.Now()
+	require.Equal(t, 0, cmd.Run([]string{"-timeout", "3s", "-retry-interval", "100ms", addr}))
+	require.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+}